@@ -0,0 +1,290 @@
+// Package catalog resolves codes of the imported catalog tables, such as
+// EDE110 in T12510, without writing SQL against catalog_values:
+//
+//	c := catalog.New(db)
+//	entry, err := c.Lookup(ctx, "T12510", "EDE110")
+//	if errors.Is(err, catalog.ErrUnknownCode) {
+//		...
+//	}
+//
+// Codes are read from one version of each table: the pinned one when
+// WithVersions gives it, otherwise the active (promoted) version, or the
+// highest imported one when none was promoted. A table is loaded whole on its
+// first use and kept in memory; Warm loads tables up front and Invalidate
+// drops them so the next use reads the database again.
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/lantoniomiranda/shitreader/internal/tableversion"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+// Querier runs the catalog's queries. *sql.DB and *sql.Tx both satisfy it, so
+// a Catalog can read inside a transaction that has not been committed yet.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Entry is a code of a catalog table in the version it was read from.
+type Entry struct {
+	TableCode   string `json:"table_code"`
+	Version     string `json:"version"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+type table struct {
+	version string
+	entries []Entry
+	byCode  map[string]Entry
+}
+
+type Catalog struct {
+	db       Querier
+	versions map[string]string
+	// load reads a table from the database; tests replace it.
+	load func(ctx context.Context, tableCode string) (*table, error)
+
+	mu     sync.RWMutex
+	tables map[string]*table
+}
+
+type Option func(*Catalog)
+
+// WithVersions pins table codes to a version, for example
+// {"T12510": "V01.00"}. Tables that are not pinned use their current version.
+func WithVersions(versions map[string]string) Option {
+	return func(c *Catalog) {
+		for tableCode, version := range versions {
+			c.versions[tableCode] = version
+		}
+	}
+}
+
+func New(db Querier, opts ...Option) *Catalog {
+	c := &Catalog{
+		db:       db,
+		versions: map[string]string{},
+		tables:   map[string]*table{},
+	}
+	c.load = c.loadTable
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Lookup returns a code of a table. An error wrapping ErrUnknownCode means the
+// table was found but the code is not in its version.
+func (c *Catalog) Lookup(ctx context.Context, tableCode string, code string) (Entry, error) {
+	t, err := c.table(ctx, tableCode)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry, ok := t.byCode[code]
+	if !ok {
+		return Entry{}, &Error{TableCode: tableCode, Version: t.version, Code: code, Err: ErrUnknownCode}
+	}
+	return entry, nil
+}
+
+// Exists reports whether a code is in a table. Only a table that cannot be
+// read is an error.
+func (c *Catalog) Exists(ctx context.Context, tableCode string, code string) (bool, error) {
+	t, err := c.table(ctx, tableCode)
+	if err != nil {
+		return false, err
+	}
+	_, ok := t.byCode[code]
+	return ok, nil
+}
+
+// List returns every code of a table, ordered by code.
+func (c *Catalog) List(ctx context.Context, tableCode string) ([]Entry, error) {
+	t, err := c.table(ctx, tableCode)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(t.entries), nil
+}
+
+// Version returns the version of a table that codes are read from.
+func (c *Catalog) Version(ctx context.Context, tableCode string) (string, error) {
+	t, err := c.table(ctx, tableCode)
+	if err != nil {
+		return "", err
+	}
+	return t.version, nil
+}
+
+// Warm loads tables into the cache, or every imported catalog when no table
+// code is given.
+func (c *Catalog) Warm(ctx context.Context, tableCodes ...string) error {
+	if len(tableCodes) == 0 {
+		var err error
+		if tableCodes, err = c.tableCodes(ctx); err != nil {
+			return err
+		}
+	}
+	for _, tableCode := range tableCodes {
+		t, err := c.load(ctx, tableCode)
+		if err != nil {
+			return err
+		}
+		c.store(tableCode, t)
+	}
+	return nil
+}
+
+// Invalidate drops tables from the cache, or every table when no table code
+// is given, so they are read again on their next use.
+func (c *Catalog) Invalidate(tableCodes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(tableCodes) == 0 {
+		c.tables = map[string]*table{}
+		return
+	}
+	for _, tableCode := range tableCodes {
+		delete(c.tables, tableCode)
+	}
+}
+
+func (c *Catalog) table(ctx context.Context, tableCode string) (*table, error) {
+	c.mu.RLock()
+	t, ok := c.tables[tableCode]
+	c.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := c.load(ctx, tableCode)
+	if err != nil {
+		return nil, err
+	}
+	c.store(tableCode, t)
+	return t, nil
+}
+
+func (c *Catalog) store(tableCode string, t *table) {
+	c.mu.Lock()
+	c.tables[tableCode] = t
+	c.mu.Unlock()
+}
+
+func (c *Catalog) loadTable(ctx context.Context, tableCode string) (*table, error) {
+	catalogID, err := c.catalogID(ctx, tableCode)
+	if err != nil {
+		return nil, err
+	}
+	versionID, version, err := c.resolveVersion(ctx, tableCode)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT code, description
+		FROM catalog_values
+		WHERE catalog_id = $1 AND table_version_id = $2 AND deleted_at IS NULL
+		ORDER BY code
+	`, catalogID, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s %s: %w", tableCode, version, err)
+	}
+	defer rows.Close()
+
+	t := &table{version: version, byCode: map[string]Entry{}}
+	for rows.Next() {
+		entry := Entry{TableCode: tableCode, Version: version}
+		if err := rows.Scan(&entry.Code, &entry.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan %s value: %w", tableCode, err)
+		}
+		t.entries = append(t.entries, entry)
+		t.byCode[entry.Code] = entry
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load %s %s: %w", tableCode, version, err)
+	}
+	return t, nil
+}
+
+// catalogID finds the catalog of a table code, by the code it was imported
+// with or, for catalogs imported before codes were kept, by its declared slug.
+func (c *Catalog) catalogID(ctx context.Context, tableCode string) (string, error) {
+	slug, ok := types.TableCodeMap[tableCode]
+	if !ok {
+		slug = tableCode
+	}
+
+	var id string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT id FROM catalogs
+		WHERE table_code = $1 OR slug = $2
+		ORDER BY table_code IS NOT DISTINCT FROM $1 DESC
+		LIMIT 1
+	`, tableCode, slug).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", &Error{TableCode: tableCode, Err: ErrUnknownTable}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find catalog %s: %w", tableCode, err)
+	}
+	return id, nil
+}
+
+// resolveVersion picks the pinned version of a table code or its current one:
+// the active version, or the highest imported one when none was promoted.
+func (c *Catalog) resolveVersion(ctx context.Context, tableCode string) (string, string, error) {
+	var id, version string
+	var err error
+	if pinned, ok := c.versions[tableCode]; ok {
+		err = c.db.QueryRowContext(ctx, `
+			SELECT id, version FROM table_versions
+			WHERE table_code = $1 AND version = $2 AND deleted_at IS NULL
+		`, tableCode, pinned).Scan(&id, &version)
+		if err == sql.ErrNoRows {
+			return "", "", &Error{TableCode: tableCode, Version: pinned, Err: ErrUnknownVersion}
+		}
+	} else {
+		err = c.db.QueryRowContext(ctx, tableversion.CurrentQuery, tableCode).Scan(&id, &version)
+		if err == sql.ErrNoRows {
+			return "", "", &Error{TableCode: tableCode, Err: ErrUnknownTable}
+		}
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve the version of %s: %w", tableCode, err)
+	}
+	return id, version, nil
+}
+
+func (c *Catalog) tableCodes(ctx context.Context) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT DISTINCT table_code FROM catalogs
+		WHERE table_code IS NOT NULL
+		ORDER BY table_code
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalogs: %w", err)
+	}
+	defer rows.Close()
+
+	var tableCodes []string
+	for rows.Next() {
+		var tableCode string
+		if err := rows.Scan(&tableCode); err != nil {
+			return nil, fmt.Errorf("failed to scan catalog: %w", err)
+		}
+		tableCodes = append(tableCodes, tableCode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list catalogs: %w", err)
+	}
+	return tableCodes, nil
+}
@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// fakeCatalog returns a Catalog that reads its tables from memory and counts
+// how many times each was loaded.
+func fakeCatalog(tables map[string][]Entry, versions map[string]string) (*Catalog, map[string]int) {
+	loads := map[string]int{}
+	c := New(nil)
+	c.load = func(ctx context.Context, tableCode string) (*table, error) {
+		loads[tableCode]++
+		entries, ok := tables[tableCode]
+		if !ok {
+			return nil, &Error{TableCode: tableCode, Err: ErrUnknownTable}
+		}
+		t := &table{version: versions[tableCode], byCode: map[string]Entry{}}
+		for _, entry := range entries {
+			t.entries = append(t.entries, entry)
+			t.byCode[entry.Code] = entry
+		}
+		return t, nil
+	}
+	return c, loads
+}
+
+var powers = []Entry{
+	{TableCode: "T12510", Version: "V02.00", Code: "EDE110", Description: "1,15 kVA"},
+	{TableCode: "T12510", Version: "V02.00", Code: "EDE345", Description: "3,45 kVA"},
+}
+
+func TestLookup(t *testing.T) {
+	c, _ := fakeCatalog(map[string][]Entry{"T12510": powers}, map[string]string{"T12510": "V02.00"})
+	ctx := context.Background()
+
+	entry, err := c.Lookup(ctx, "T12510", "EDE345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != powers[1] {
+		t.Errorf("Lookup(T12510, EDE345) = %+v, want %+v", entry, powers[1])
+	}
+
+	_, err = c.Lookup(ctx, "T12510", "EDE999")
+	if !errors.Is(err, ErrUnknownCode) {
+		t.Fatalf("Lookup(T12510, EDE999) error = %v, want ErrUnknownCode", err)
+	}
+	var catalogErr *Error
+	if !errors.As(err, &catalogErr) || catalogErr.Version != "V02.00" || catalogErr.Code != "EDE999" {
+		t.Errorf("Lookup(T12510, EDE999) error = %#v, want the table version and code", err)
+	}
+	if want := "unknown code: EDE999 not found in T12510 V02.00"; err.Error() != want {
+		t.Errorf("error message = %q, want %q", err.Error(), want)
+	}
+
+	_, err = c.Lookup(ctx, "T99999", "X01")
+	if !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("Lookup(T99999, X01) error = %v, want ErrUnknownTable", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	c, _ := fakeCatalog(map[string][]Entry{"T12510": powers}, map[string]string{"T12510": "V02.00"})
+	ctx := context.Background()
+
+	for code, want := range map[string]bool{"EDE110": true, "EDE999": false} {
+		ok, err := c.Exists(ctx, "T12510", code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("Exists(T12510, %s) = %v, want %v", code, ok, want)
+		}
+	}
+
+	if _, err := c.Exists(ctx, "T99999", "X01"); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("Exists(T99999, X01) error = %v, want ErrUnknownTable", err)
+	}
+}
+
+func TestListReturnsACopy(t *testing.T) {
+	c, _ := fakeCatalog(map[string][]Entry{"T12510": powers}, map[string]string{"T12510": "V02.00"})
+	ctx := context.Background()
+
+	entries, err := c.List(ctx, "T12510")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries[0].Code = "CHANGED"
+
+	entries, err = c.List(ctx, "T12510")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(entries, powers) {
+		t.Errorf("List(T12510) = %+v after changing a returned entry, want %+v", entries, powers)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, loads := fakeCatalog(map[string][]Entry{"T12510": powers, "T10120": nil}, map[string]string{"T12510": "V02.00"})
+	ctx := context.Background()
+
+	lookup := func() {
+		t.Helper()
+		if _, err := c.Lookup(ctx, "T12510", "EDE110"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lookup()
+	lookup()
+	if loads["T12510"] != 1 {
+		t.Errorf("T12510 loaded %d times, want once while cached", loads["T12510"])
+	}
+
+	c.Invalidate("T10120")
+	lookup()
+	if loads["T12510"] != 1 {
+		t.Errorf("T12510 loaded %d times after invalidating another table, want 1", loads["T12510"])
+	}
+
+	c.Invalidate("T12510")
+	lookup()
+	if loads["T12510"] != 2 {
+		t.Errorf("T12510 loaded %d times after invalidating it, want 2", loads["T12510"])
+	}
+
+	c.Invalidate()
+	lookup()
+	if loads["T12510"] != 3 {
+		t.Errorf("T12510 loaded %d times after invalidating everything, want 3", loads["T12510"])
+	}
+
+	if err := c.Warm(ctx, "T12510", "T10120"); err != nil {
+		t.Fatal(err)
+	}
+	lookup()
+	if loads["T12510"] != 4 || loads["T10120"] != 1 {
+		t.Errorf("loads after Warm = %v, want T12510 reloaded once and T10120 loaded once", loads)
+	}
+
+	if err := c.Warm(ctx, "T99999"); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("Warm(T99999) error = %v, want ErrUnknownTable", err)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{&Error{TableCode: "T12510", Err: ErrUnknownTable}, "unknown table: T12510"},
+		{&Error{TableCode: "T12510", Version: "V09.00", Err: ErrUnknownVersion}, "unknown version: T12510 V09.00 has not been imported"},
+		{&Error{TableCode: "T12510", Version: "V02.00", Code: "X01", Err: ErrUnknownCode}, "unknown code: X01 not found in T12510 V02.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+		if !errors.Is(tt.err, tt.err.Err) {
+			t.Errorf("errors.Is(%v, %v) = false", tt.err, tt.err.Err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownTable means no catalog or no version was imported for a table
+	// code.
+	ErrUnknownTable = errors.New("unknown table")
+	// ErrUnknownVersion means a pinned version of a table was not imported.
+	ErrUnknownVersion = errors.New("unknown version")
+	// ErrUnknownCode means a code is not in the version of a table read.
+	ErrUnknownCode = errors.New("unknown code")
+)
+
+// Error says what could not be resolved. Err is one of ErrUnknownTable,
+// ErrUnknownVersion and ErrUnknownCode, so callers can use errors.Is.
+type Error struct {
+	TableCode string
+	Version   string
+	Code      string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Code != "":
+		return fmt.Sprintf("%s: %s not found in %s %s", e.Err, e.Code, e.TableCode, e.Version)
+	case e.Version != "":
+		return fmt.Sprintf("%s: %s %s has not been imported", e.Err, e.TableCode, e.Version)
+	default:
+		return fmt.Sprintf("%s: %s", e.Err, e.TableCode)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
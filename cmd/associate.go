@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/manifest"
+	"github.com/lantoniomiranda/shitreader/internal/services"
+)
+
+var associateSteps = map[string]manifest.Kind{
+	"fields":       manifest.KindRecordFields,
+	"record-types": manifest.KindRecordTypes,
+	"steps":        manifest.KindStepRecords,
+}
+
+func runAssociate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("associate", flag.ExitOnError)
+	manifestPath := fs.String("manifest", defaultManifest, "import manifest")
+	step := fs.String("step", "", "run only this stage: fields, record-types or steps")
+	file := fs.String("file", "", "workbook for the record-types or steps stage")
+	sheet := fs.String("sheet", "", "sheet name to read (default: the manifest sheet)")
+	atomic := fs.Bool("atomic", false, "run every stage in a single transaction")
+	jobs := fs.Int("jobs", defaultJobs, "number of independent sources to run concurrently (1 with --atomic)")
+	var sources stringList
+	fs.Var(&sources, "source", "run only these manifest sources (repeatable or comma-separated)")
+	var tableVersions stringList
+	fs.Var(&tableVersions, "table-version", "link rows of this version of a table, as T-code=version (repeatable or comma-separated, default: the current version)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	versions, err := versionsFlag(tableVersions)
+	if err != nil {
+		return err
+	}
+
+	kind, ok := associateSteps[*step]
+	if *step != "" && !ok {
+		return fmt.Errorf("unknown step %q", *step)
+	}
+	if *file != "" && kind != manifest.KindRecordTypes && kind != manifest.KindStepRecords {
+		return fmt.Errorf("--file requires --step record-types or --step steps")
+	}
+
+	m, err := manifest.Load(*manifestPath)
+	if err != nil {
+		return err
+	}
+
+	selected, err := m.Ordered(func(src manifest.Source) bool {
+		if src.Kind.IsImport() {
+			return false
+		}
+		if *step != "" && src.Kind != kind {
+			return false
+		}
+		return len(sources) == 0 || contains(sources, src.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	tasks := make([]task, 0, len(selected))
+	for _, src := range selected {
+		if *file != "" {
+			src.Path = *file
+			src.Format = ""
+		}
+		if *sheet != "" {
+			src.Sheet = *sheet
+		}
+		src.SetDefaults()
+		tasks = append(tasks, sourceTask(application, src, services.ReadOptions{Versions: versions}))
+	}
+
+	_, err = runPipeline(ctx, application, tasks, *atomic, *jobs)
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+var diffFormats = map[string]func(io.Writer, *types.Diff) error{
+	"text":     printDiffText,
+	"json":     printDiffJSON,
+	"markdown": printDiffMarkdown,
+}
+
+func runDiff(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json or markdown")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 3 {
+		return fmt.Errorf("usage: diff <table-code> <from-version> <to-version> [--format text|json|markdown]")
+	}
+	printDiff, ok := diffFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	diff, err := application.QueryService.Diff(ctx, positional[0], positional[1], positional[2])
+	if err != nil {
+		return err
+	}
+
+	return printDiff(os.Stdout, diff)
+}
+
+func printDiffText(out io.Writer, diff *types.Diff) error {
+	fmt.Fprintf(out, "%s %s -> %s\n", diffTitle(diff), diff.FromVersion, diff.ToVersion)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, v := range diff.Added {
+		fmt.Fprintf(w, "+ %s\t%s\n", v.Code, v.Description)
+	}
+	for _, v := range diff.Removed {
+		fmt.Fprintf(w, "- %s\t%s\n", v.Code, v.Description)
+	}
+	for _, c := range diff.Changed {
+		if c.Column != "" {
+			fmt.Fprintf(w, "~ %s\t%s: %q -> %q\n", c.Code, c.Column, c.From, c.To)
+			continue
+		}
+		fmt.Fprintf(w, "~ %s\t%q -> %q\n", c.Code, c.From, c.To)
+	}
+	for _, l := range diff.Links {
+		var targets []string
+		for _, t := range l.Added {
+			targets = append(targets, "+"+t)
+		}
+		for _, t := range l.Removed {
+			targets = append(targets, "-"+t)
+		}
+		fmt.Fprintf(w, "~ %s\t%s: %s\n", l.Code, l.Link, strings.Join(targets, " "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, diffSummary(diff))
+	return err
+}
+
+func diffSummary(diff *types.Diff) string {
+	summary := fmt.Sprintf("%d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	if len(diff.Links) > 0 {
+		summary += fmt.Sprintf(", %d links changed", len(diff.Links))
+	}
+	return summary
+}
+
+func printDiffJSON(out io.Writer, diff *types.Diff) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
+
+func printDiffMarkdown(out io.Writer, diff *types.Diff) error {
+	fmt.Fprintf(out, "# %s %s → %s\n\n", diffTitle(diff), diff.FromVersion, diff.ToVersion)
+	fmt.Fprintf(out, "%s.\n", diffSummary(diff))
+
+	printValues := func(title string, values []types.Value) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "\n## %s\n\n| Code | Description |\n| --- | --- |\n", title)
+		for _, v := range values {
+			fmt.Fprintf(out, "| %s | %s |\n", markdownCell(v.Code), markdownCell(v.Description))
+		}
+	}
+	printValues("Added", diff.Added)
+	printValues("Removed", diff.Removed)
+
+	if len(diff.Changed) > 0 {
+		fmt.Fprintf(out, "\n## Changed\n\n| Code | %s | %s |\n| --- | --- | --- |\n", diff.FromVersion, diff.ToVersion)
+		for _, c := range diff.Changed {
+			code := c.Code
+			if c.Column != "" {
+				code += " (" + c.Column + ")"
+			}
+			fmt.Fprintf(out, "| %s | %s | %s |\n", markdownCell(code), markdownCell(c.From), markdownCell(c.To))
+		}
+	}
+
+	if len(diff.Links) > 0 {
+		fmt.Fprintf(out, "\n## Links\n\n| Code | Link | Added | Removed |\n| --- | --- | --- | --- |\n")
+		for _, l := range diff.Links {
+			fmt.Fprintf(out, "| %s | %s | %s | %s |\n", markdownCell(l.Code), l.Link,
+				markdownCell(strings.Join(l.Added, ", ")), markdownCell(strings.Join(l.Removed, ", ")))
+		}
+	}
+	return nil
+}
+
+func diffTitle(diff *types.Diff) string {
+	if diff.Table == "" {
+		return diff.TableCode
+	}
+	return diff.TableCode + " " + diff.Table
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
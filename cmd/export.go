@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	version := fs.String("version", "", "table version to export (default: the active version)")
+	asOf := fs.String("as-of", "", "export the version that applied on this date, as YYYY-MM-DD")
+	format := fs.String("format", "csv", "output format: csv or json")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: export <table-code> [--version V | --as-of YYYY-MM-DD] [--format csv|json]")
+	}
+	if *version != "" && *asOf != "" {
+		return fmt.Errorf("--version and --as-of cannot be used together")
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	if *asOf != "" {
+		date, err := parseDate("--as-of", *asOf)
+		if err != nil {
+			return err
+		}
+		if *version, err = application.QueryService.VersionAsOf(ctx, positional[0], date); err != nil {
+			return err
+		}
+	}
+
+	values, err := application.QueryService.Values(ctx, positional[0], *version)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"table_code", "version", "code", "description"}); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := w.Write([]string{v.TableCode, v.Version, v.Code, v.Description}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
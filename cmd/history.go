@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+// bookkeeping columns are left out of the changed values.
+var bookkeeping = []string{"id", "created_at", "updated_at", "table_version_id", "catalog_id"}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: history <table-code> <code>")
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	changes, err := application.QueryService.History(ctx, positional[0], positional[1])
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("no changes recorded for %s in %s", positional[1], positional[0])
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGED\tRUN\tVERSION\tOPERATION\tVALUES")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			formatTime(&c.ChangedAt), runLabel(c.RunID), c.Version, strings.ToLower(c.Operation), changedValues(c))
+	}
+	return w.Flush()
+}
+
+// changedValues describes the columns a change set or modified, as
+// column: "old" -> "new".
+func changedValues(c types.Change) string {
+	columns := make([]string, 0, len(c.New)+len(c.Old))
+	for column := range c.New {
+		columns = append(columns, column)
+	}
+	for column := range c.Old {
+		if _, ok := c.New[column]; !ok {
+			columns = append(columns, column)
+		}
+	}
+	slices.Sort(columns)
+
+	var parts []string
+	for _, column := range columns {
+		if slices.Contains(bookkeeping, column) {
+			continue
+		}
+		before, after := c.Old[column], c.New[column]
+		switch {
+		case c.Old == nil:
+			if after != nil {
+				parts = append(parts, fmt.Sprintf("%s: %s", column, formatValue(after)))
+			}
+		case c.New == nil:
+			if before != nil {
+				parts = append(parts, fmt.Sprintf("%s: %s", column, formatValue(before)))
+			}
+		case !reflect.DeepEqual(before, after):
+			parts = append(parts, fmt.Sprintf("%s: %s -> %s", column, formatValue(before), formatValue(after)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v any) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v))
+}
+
+func runLabel(id string) string {
+	if id == "" {
+		return "-"
+	}
+	return shortID(id)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+
+	"github.com/lantoniomiranda/shitreader/internal/manifest"
+	"github.com/lantoniomiranda/shitreader/internal/services"
+)
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	manifestPath := fs.String("manifest", defaultManifest, "import manifest")
+	file := fs.String("file", "", "import only this workbook")
+	sheet := fs.String("sheet", "", "sheet name to read (default: the manifest sheet)")
+	force := fs.Bool("force", false, "import workbooks even if their content hash has not changed")
+	atomic := fs.Bool("atomic", false, "run every source in a single transaction")
+	jobs := fs.Int("jobs", defaultJobs, "number of independent sources to run concurrently (1 with --atomic)")
+	strict := fs.Bool("strict", false, "fail the import when rows do not fit the block format")
+	var normalizeRules stringList
+	fs.Var(&normalizeRules, "normalize", "text normalization rules: nfc, invisible, spaces, trim, dashes, default, all or none (default: default)")
+	autoRegister := fs.Bool("auto-register", false, "store blocks with unknown table codes as catalogs instead of quarantining them")
+	slugsPath := fs.String("slugs", "", "JSON file mapping auto-registered table codes to catalog slugs (default: the table code)")
+	failOnUnknown := fs.Bool("fail-on-unknown", false, "exit with an error when blocks with unknown table codes were quarantined")
+	var sources, tables stringList
+	fs.Var(&sources, "source", "import only these manifest sources (repeatable or comma-separated)")
+	fs.Var(&tables, "table", "import only these table codes (repeatable or comma-separated)")
+	var protect stringList
+	fs.Var(&protect, "protect", "keep these codes even when they are missing from the source, as T-code=code (repeatable or comma-separated)")
+	var tableVersions stringList
+	fs.Var(&tableVersions, "table-version", "link rows of this version of a table, as T-code=version (repeatable or comma-separated, default: the current version)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	versions, err := versionsFlag(tableVersions)
+	if err != nil {
+		return err
+	}
+
+	protected, err := protectFlag(protect)
+	if err != nil {
+		return err
+	}
+
+	rules, err := normalizeFlag(normalizeRules)
+	if err != nil {
+		return err
+	}
+
+	slugs, err := autoRegisterFlag(*autoRegister, *slugsPath)
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(*manifestPath)
+	if err != nil {
+		return err
+	}
+
+	selected, err := m.Ordered(func(src manifest.Source) bool {
+		if !src.Kind.IsImport() {
+			return false
+		}
+		if len(sources) > 0 && !contains(sources, src.Name) {
+			return false
+		}
+		if *file != "" && !matchesFile(src, *file) {
+			return false
+		}
+		if len(tables) > 0 && src.Kind != manifest.KindBlockCatalog {
+			return len(sources) > 0 || *file != ""
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if *file != "" && len(selected) == 0 {
+		selected = []manifest.Source{{
+			Name: filepath.Base(*file),
+			Path: *file,
+			Kind: manifest.KindBlockCatalog,
+		}}
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	opts := services.ReadOptions{Tables: tables, Force: *force, Strict: *strict, Normalize: rules,
+		AutoRegister: *autoRegister, Slugs: slugs, Versions: versions,
+		Protect: protected}
+
+	tasks := make([]task, 0, len(selected))
+	for _, src := range selected {
+		if *sheet != "" {
+			src.Sheet = *sheet
+		}
+		src.SetDefaults()
+		tasks = append(tasks, sourceTask(application, src, opts))
+	}
+
+	runs, err := runPipeline(ctx, application, tasks, *atomic, *jobs)
+	if err != nil {
+		return err
+	}
+	if *failOnUnknown {
+		return checkQuarantine(runs)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+func runLookup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	asOf := fs.String("as-of", "", "only the version that applied on this date, as YYYY-MM-DD")
+	all := fs.Bool("all", false, "every imported version instead of the active one")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: lookup <table-code> <code> [--as-of YYYY-MM-DD | --all]")
+	}
+	if *asOf != "" && *all {
+		return fmt.Errorf("--as-of and --all cannot be used together")
+	}
+	var date time.Time
+	if *asOf != "" {
+		if date, err = parseDate("--as-of", *asOf); err != nil {
+			return err
+		}
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	values, err := application.QueryService.Lookup(ctx, positional[0], positional[1])
+	if err != nil {
+		return err
+	}
+	if !*all {
+		var version string
+		if *asOf != "" {
+			version, err = application.QueryService.VersionAsOf(ctx, positional[0], date)
+		} else {
+			version, err = application.QueryService.CurrentVersion(ctx, positional[0])
+		}
+		if err != nil {
+			return err
+		}
+		values = slices.DeleteFunc(values, func(v types.Value) bool { return v.Version != version })
+		if len(values) == 0 && *asOf != "" {
+			return fmt.Errorf("code %s not found in %s %s, which applied on %s", positional[1], positional[0], version, *asOf)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("code %s not found in %s %s", positional[1], positional[0], version)
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("code %s not found in %s", positional[1], positional[0])
+	}
+
+	for _, v := range values {
+		fmt.Printf("%s\t%s\t%s\t%s\n", v.TableCode, v.Version, v.Code, v.Description)
+	}
+	return nil
+}
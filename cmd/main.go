@@ -1,24 +1,47 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"path/filepath"
+	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 
 	"github.com/joho/godotenv"
 	"github.com/lantoniomiranda/shitreader/internal/app"
+	"github.com/lantoniomiranda/shitreader/internal/types"
 )
 
-type fileConfig struct {
-	path  string
-	sheet string
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
 }
 
 type task struct {
+	id   string
 	name string
-	run  func() error
+	deps []string
+	run  func(ctx context.Context) (*types.ImportRun, error)
+}
+
+var commands = []command{
+	{"run", "run [--manifest path] [--jobs N] [--force] [--atomic] [--strict] [--normalize rules] [--auto-register [--slugs path]] [--table-version T-code=V,...] [--protect T-code=code,...] [--fail-on-unknown]", "Run every manifest source: imports, then associations", runAll},
+	{"import", "import [--manifest path] [--jobs N] [--source name,...] [--file path] [--sheet name] [--table T-code,...] [--force] [--atomic] [--strict] [--normalize rules] [--auto-register [--slugs path]] [--table-version T-code=V,...] [--protect T-code=code,...] [--fail-on-unknown]", "Import table workbooks and process steps", runImport},
+	{"associate", "associate [--manifest path] [--jobs N] [--step fields|record-types|steps] [--source name,...] [--file path] [--sheet name] [--table-version T-code=V,...] [--atomic]", "Link records, fields, record types and steps", runAssociate},
+	{"status", "status [--runs N] [--versions] [--quarantine]", "Show when each table was last refreshed", runStatus},
+	{"export", "export <table-code> [--version V | --as-of YYYY-MM-DD] [--format csv|json]", "Print the values of a table", runExport},
+	{"diff", "diff <table-code> <from-version> <to-version> [--format text|json|markdown]", "Compare two versions of a table", runDiff},
+	{"history", "history <table-code> <code>", "Show how a code changed over time", runHistory},
+	{"lookup", "lookup <table-code> <code> [--as-of YYYY-MM-DD | --all]", "Resolve a code to its description", runLookup},
+	{"promote", "promote <table-code> <version> [--force]", "Make a table version the one read by default", runPromote},
+	{"rollback", "rollback <run-id> [--force]", "Undo the changes of an import run", runRollback},
+	{"validity", "validity <table-code> <version> --from YYYY-MM-DD [--to YYYY-MM-DD]", "Set the dates a table version applies", runValidity},
+	{"migrate", "migrate [up|down|status|version|redo]", "Run database migrations", runMigrate},
 }
 
 func main() {
@@ -26,94 +49,88 @@ func main() {
 		log.Printf("WARNING: .env not loaded: %v", err)
 	}
 
-	app, err := app.NewApplication()
-	if err != nil {
-		log.Fatalf("Failed to create application: %v", err)
-	}
-	defer app.DB.Close()
-
-	files := []fileConfig{
-		{"files/tabelas-dados.xlsx", "Data"},
-		{"files/cae.xlsx", "Data"},
-		{"files/paises.xlsx", "Data"},
-		{"files/distritos.xlsx", "Data"},
-		{"files/concelhos.xlsx", "Data"},
-		{"files/freguesias.xlsx", "Data"},
-		{"files/ine-zonas.xlsx", "Data"},
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
 	}
 
-	tasks := make([]task, 0, len(files)+3)
-	for _, file := range files {
-		file := file
-		tasks = append(tasks, task{
-			name: fmt.Sprintf("Import %s", filepath.Base(file.path)),
-			run: func() error {
-				return app.ReaderService.Read(file.path, file.sheet)
-			},
-		})
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
 	}
 
-	tasks = append(tasks,
-		task{
-			name: "Inspect processo-passos.xlsx",
-			run: func() error {
-				return app.ReaderService.ReadProcessSteps("files/processo-passos.xlsx", "Data")
-			},
-		},
-		task{
-			name: "Associate fields with records",
-			run: func() error {
-				return app.AssociationService.Associate()
-			},
-		},
-		task{
-			name: "Associate record types",
-			run: func() error {
-				return app.AssociationService.AssociateRecordTypes("files/record-types.xlsx", "Data")
-			},
-		},
-		task{
-			name: "Associate steps",
-			run: func() error {
-				return app.AssociationService.AssociateSteps("files/passo-registos.xlsx", "Data")
-			},
-		},
-	)
-
-	totalTasks := len(tasks)
-	start := time.Now()
-
-	renderProgress(0, totalTasks, "Starting...", start)
-
-	for i, task := range tasks {
-		renderProgress(i, totalTasks, task.name, start)
-		if err := task.run(); err != nil {
-			log.Fatalf("Task %q failed: %v", task.name, err)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		// The first signal cancels the command; after it the default
+		// handling is restored, so a second one kills the process.
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		err := cmd.run(ctx, os.Args[2:])
+		stop()
+		if err != nil {
+			log.Fatalf("%s: %v", cmd.name, err)
 		}
+		return
 	}
 
-	renderProgress(totalTasks, totalTasks, "Completed\n", start)
-	fmt.Printf("\nAll tasks finished in %s\n", time.Since(start).Round(time.Millisecond))
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(2)
 }
 
-func renderProgress(completed, total int, current string, start time.Time) {
-	if total == 0 {
-		return
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: shitreader <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+		fmt.Fprintf(os.Stderr, "  %-10s   %s\n", "", cmd.usage)
+	}
+}
+
+func openApplication(ctx context.Context) (*app.Application, error) {
+	application, err := app.NewApplication(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create application: %w", err)
 	}
-	barWidth := 40
-	percent := float64(completed) / float64(total)
-	filled := int(percent * float64(barWidth))
-	if filled > barWidth {
-		filled = barWidth
+	return application, nil
+}
+
+// parseArgs parses flags wherever they appear so positional arguments can
+// come first, as in "export T10051 --version V01.00".
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			*l = append(*l, v)
+		}
 	}
-	bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
-	elapsed := time.Since(start).Round(time.Millisecond)
-	fmt.Printf("\r[%s] %2.0f%% %d/%d | Elapsed: %s | Current: %s",
-		bar,
-		percent*100,
-		completed,
-		total,
-		elapsed,
-		current,
-	)
+	return nil
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/migrations"
+)
+
+func runMigrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(positional) > 0 {
+		command = positional[0]
+		positional = positional[1:]
+	}
+
+	db, err := store.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return store.MigrateCommandFS(ctx, db, migrations.FS, ".", command, positional...)
+}
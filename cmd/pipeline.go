@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lantoniomiranda/shitreader/internal/app"
+	"github.com/lantoniomiranda/shitreader/internal/manifest"
+	"github.com/lantoniomiranda/shitreader/internal/normalize"
+	"github.com/lantoniomiranda/shitreader/internal/services"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+const defaultJobs = 4
+
+// runPipeline runs the tasks, and with atomic set it runs all of them inside
+// one transaction that is only committed when every task succeeded. A single
+// transaction cannot be shared between workers, so atomic pipelines run one
+// task at a time.
+func runPipeline(ctx context.Context, application *app.Application, tasks []task, atomic bool, jobs int) ([]*types.ImportRun, error) {
+	if !atomic {
+		return runTasks(ctx, tasks, jobs)
+	}
+
+	tx, err := application.Transactions.BeginPipeline(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The runs are recorded on their own connection, so they stay pending
+	// until the pipeline is committed and a crash in between does not leave
+	// succeeded runs for changes that were never kept.
+	runs, err := runTasks(services.WithPendingRuns(ctx), tasks, 1)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.Printf("WARNING: failed to roll back pipeline: %v", rbErr)
+		}
+		markRolledBack(ctx, application, runs)
+		return runs, fmt.Errorf("%w (pipeline rolled back, no changes were kept)", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		markRolledBack(ctx, application, runs)
+		return runs, fmt.Errorf("failed to commit pipeline: %w", err)
+	}
+	if err := application.LedgerService.MarkSucceeded(ctx, runs); err != nil {
+		log.Printf("WARNING: pipeline committed but its runs are still pending: %v", err)
+	}
+	return runs, nil
+}
+
+func markRolledBack(ctx context.Context, application *app.Application, runs []*types.ImportRun) {
+	if err := application.LedgerService.MarkRolledBack(ctx, runs); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+func normalizeFlag(list stringList) ([]normalize.Rule, error) {
+	if len(list) == 0 {
+		return normalize.Default, nil
+	}
+	return normalize.Parse(list)
+}
+
+func autoRegisterFlag(enabled bool, slugsPath string) (map[string]string, error) {
+	if slugsPath == "" {
+		return nil, nil
+	}
+	if !enabled {
+		return nil, fmt.Errorf("--slugs needs --auto-register")
+	}
+	return manifest.LoadSlugs(slugsPath)
+}
+
+// versionsFlag reads table version pins given as T-code=version.
+func versionsFlag(list stringList) (types.Versions, error) {
+	versions := make(types.Versions, len(list))
+	for _, pin := range list {
+		code, version, err := splitTableFlag(pin, "table version", "version")
+		if err != nil {
+			return nil, err
+		}
+		if _, known := types.TableCodeMap[code]; !known {
+			return nil, fmt.Errorf("invalid table version %q: unknown table code %s", pin, code)
+		}
+		versions[code] = version
+	}
+	return versions, nil
+}
+
+// protectFlag reads codes to keep given as T-code=code. Auto-registered
+// tables can be protected too, so the table code is not checked.
+func protectFlag(list stringList) (map[string][]string, error) {
+	protect := make(map[string][]string)
+	for _, item := range list {
+		tableCode, code, err := splitTableFlag(item, "protected code", "code")
+		if err != nil {
+			return nil, err
+		}
+		protect[tableCode] = append(protect[tableCode], code)
+	}
+	return protect, nil
+}
+
+func splitTableFlag(value string, what string, part string) (string, string, error) {
+	tableCode, v, ok := strings.Cut(value, "=")
+	if !ok || tableCode == "" || v == "" {
+		return "", "", fmt.Errorf("invalid %s %q, expected T-code=%s", what, value, part)
+	}
+	return tableCode, v, nil
+}
+
+func checkQuarantine(runs []*types.ImportRun) error {
+	quarantined := 0
+	for _, run := range runs {
+		quarantined += len(run.Quarantined)
+	}
+	if quarantined > 0 {
+		return fmt.Errorf("%d blocks with unknown table codes were quarantined", quarantined)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func runPromote(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	force := fs.Bool("force", false, "promote even when the version is older than the active one")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: promote <table-code> <version> [--force]")
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	previous, err := application.VersionService.Promote(ctx, positional[0], positional[1], *force)
+	if err != nil {
+		return err
+	}
+	if previous == "" {
+		previous = "-"
+	}
+	fmt.Printf("%s: %s -> %s\n", positional[0], previous, positional[1])
+	return nil
+}
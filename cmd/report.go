@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+func printRunSummary(runs []*types.ImportRun) {
+	if len(runs) == 0 {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tKIND\tSOURCE\tSTATUS\tTABLE\tINSERTED\tUPDATED\tUNCHANGED\tSKIPPED\tDELETED")
+	for _, run := range runs {
+		if len(run.Tables) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t-\t\t\t\t\t\n", shortID(run.ID), run.Kind, run.SourceFile, run.Status)
+			continue
+		}
+		for _, t := range run.Tables {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+				shortID(run.ID), run.Kind, run.SourceFile, run.Status, tableLabel(t), t.Inserted, t.Updated, t.Unchanged, t.Skipped, t.Deleted)
+		}
+	}
+	w.Flush()
+
+	printQuarantine(runs)
+	printRowErrors(runs)
+	printWarnings(runs)
+	printDeleted(runs)
+	printTextFixes(runs)
+}
+
+func printQuarantine(runs []*types.ImportRun) {
+	var blocks []types.QuarantinedBlock
+	for _, run := range runs {
+		blocks = append(blocks, run.Quarantined...)
+	}
+	if len(blocks) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d blocks with unknown table codes were quarantined:\n", len(blocks))
+	printQuarantinedBlocks(blocks)
+}
+
+func printQuarantinedBlocks(blocks []types.QuarantinedBlock) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tVERSION\tDESCRIPTION\tLOCATION\tROWS")
+	for _, b := range blocks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s!%s!%d:%d\t%d\n",
+			b.TableCode, b.Version, b.Description, filepath.Base(b.SourceFile), b.Sheet, b.FirstRow, b.LastRow, len(b.Rows))
+	}
+	w.Flush()
+}
+
+const maxRowErrors = 20
+
+func printRowErrors(runs []*types.ImportRun) {
+	var rowErrors []types.RowError
+	for _, run := range runs {
+		rowErrors = append(rowErrors, run.RowErrors...)
+	}
+	if len(rowErrors) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d rows did not fit the block format and were skipped:\n", len(rowErrors))
+	for i, e := range rowErrors {
+		if i == maxRowErrors {
+			fmt.Printf("  ... and %d more (see import_run_errors)\n", len(rowErrors)-maxRowErrors)
+			break
+		}
+		fmt.Printf("  %s\n", e)
+	}
+}
+
+func printWarnings(runs []*types.ImportRun) {
+	var warnings []types.RowError
+	for _, run := range runs {
+		warnings = append(warnings, run.Warnings...)
+	}
+	if len(warnings) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d cells were read with warnings:\n", len(warnings))
+	for i, e := range warnings {
+		if i == maxRowErrors {
+			fmt.Printf("  ... and %d more (see import_run_errors)\n", len(warnings)-maxRowErrors)
+			break
+		}
+		fmt.Printf("  %s\n", e)
+	}
+}
+
+func printDeleted(runs []*types.ImportRun) {
+	var deleted []types.DeletedRow
+	for _, run := range runs {
+		deleted = append(deleted, run.Deleted...)
+	}
+	if len(deleted) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d rows are no longer in their source and were soft-deleted:\n", len(deleted))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tVERSION\tCODE\tDESCRIPTION")
+	for i, d := range deleted {
+		if i == maxRowErrors {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.TableCode, d.Version, d.Code, d.Description)
+	}
+	w.Flush()
+	if len(deleted) > maxRowErrors {
+		fmt.Printf("  ... and %d more (see import_run_deletions)\n", len(deleted)-maxRowErrors)
+	}
+}
+
+func printTextFixes(runs []*types.ImportRun) {
+	var fixes []types.TextFix
+	for _, run := range runs {
+		fixes = append(fixes, run.TextFixes...)
+	}
+	if len(fixes) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d cell values were normalized:\n", len(fixes))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOCATION\tRULES\tORIGINAL\tNORMALIZED")
+	for i, f := range fixes {
+		if i == maxRowErrors {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%q\t%q\n", f.Location, f.Rules, f.Original, f.Normalized)
+	}
+	w.Flush()
+	if len(fixes) > maxRowErrors {
+		fmt.Printf("  ... and %d more (see import_run_fixes)\n", len(fixes)-maxRowErrors)
+	}
+}
+
+func tableLabel(t types.TableStats) string {
+	if t.TableCode == "" {
+		return t.Table
+	}
+	return t.TableCode + " " + t.Table
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(types.DateLayout)
+}
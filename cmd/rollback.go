@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+func runRollback(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	force := fs.Bool("force", false, "roll back even when later runs changed the same rows")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: rollback <run-id> [--force]")
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	run, err := application.RollbackService.Rollback(ctx, positional[0], *force)
+	if run != nil {
+		printRunSummary([]*types.ImportRun{run})
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type taskResult struct {
+	index int
+	run   *types.ImportRun
+	err   error
+}
+
+// runTasks runs up to jobs tasks at a time. A task starts once every task it
+// depends on has succeeded; dependencies on tasks that are not in the list
+// count as satisfied. After the first failure no new tasks are started, but
+// the ones already running are allowed to finish.
+func runTasks(ctx context.Context, tasks []task, jobs int) ([]*types.ImportRun, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	totalTasks := len(tasks)
+	start := time.Now()
+
+	index := make(map[string]int, totalTasks)
+	for i, t := range tasks {
+		if t.id != "" {
+			index[t.id] = i
+		}
+	}
+
+	pending := make([]int, totalTasks)
+	dependents := make([][]int, totalTasks)
+	var ready []int
+	for i, t := range tasks {
+		for _, dep := range t.deps {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	results := make(chan taskResult)
+	runs := make([]*types.ImportRun, totalTasks)
+	running := make(map[int]bool)
+	completed := 0
+	var errs []error
+
+	renderProgress(0, totalTasks, "Starting...", start)
+
+	for {
+		for len(ready) > 0 && len(running) < jobs && len(errs) == 0 && ctx.Err() == nil {
+			i := ready[0]
+			ready = ready[1:]
+			running[i] = true
+			go func(i int, t task) {
+				run, err := t.run(ctx)
+				results <- taskResult{index: i, run: run, err: err}
+			}(i, tasks[i])
+		}
+		if len(running) == 0 {
+			break
+		}
+		renderProgress(completed, totalTasks, runningNames(tasks, running), start)
+
+		res := <-results
+		delete(running, res.index)
+		completed++
+		runs[res.index] = res.run
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("task %q failed: %w", tasks[res.index].name, res.err))
+			continue
+		}
+		for _, j := range dependents[res.index] {
+			pending[j]--
+			if pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	finished := make([]*types.ImportRun, 0, totalTasks)
+	for _, run := range runs {
+		if run != nil {
+			finished = append(finished, run)
+		}
+	}
+
+	if len(errs) == 0 && completed < totalTasks {
+		errs = append(errs, fmt.Errorf("stopped after %d of %d tasks: %w", completed, totalTasks, ctx.Err()))
+	}
+	if len(errs) > 0 {
+		fmt.Println()
+		printRunSummary(finished)
+		return finished, errors.Join(errs...)
+	}
+
+	renderProgress(totalTasks, totalTasks, "Completed\n", start)
+	fmt.Printf("\nAll tasks finished in %s\n", time.Since(start).Round(time.Millisecond))
+	printRunSummary(finished)
+	return finished, nil
+}
+
+func runningNames(tasks []task, running map[int]bool) string {
+	indexes := make([]int, 0, len(running))
+	for i := range running {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	names := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		names = append(names, tasks[i].name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// renderProgress is only called from the scheduler loop, never from the
+// workers, so progress lines do not interleave.
+func renderProgress(completed, total int, current string, start time.Time) {
+	if total == 0 {
+		return
+	}
+	barWidth := 40
+	percent := float64(completed) / float64(total)
+	filled := int(percent * float64(barWidth))
+	if filled > barWidth {
+		filled = barWidth
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	fmt.Printf("\r\033[K[%s] %2.0f%% %d/%d | Elapsed: %s | Current: %s",
+		bar,
+		percent*100,
+		completed,
+		total,
+		elapsed,
+		current,
+	)
+}
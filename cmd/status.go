@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/lantoniomiranda/shitreader/internal/app"
+)
+
+func runStatus(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	runs := fs.Int("runs", 0, "list the N most recent import runs instead")
+	versions := fs.Bool("versions", false, "list imported table versions and row counts instead")
+	quarantine := fs.Bool("quarantine", false, "list quarantined blocks with unknown table codes instead")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	switch {
+	case *runs > 0:
+		return printRuns(ctx, application, *runs)
+	case *versions:
+		return printVersions(ctx, application)
+	case *quarantine:
+		blocks, err := application.LedgerService.Quarantined(ctx)
+		if err != nil {
+			return err
+		}
+		printQuarantinedBlocks(blocks)
+		return nil
+	default:
+		return printRefreshes(ctx, application)
+	}
+}
+
+func printRefreshes(ctx context.Context, application *app.Application) error {
+	refreshes, err := application.LedgerService.Refreshes(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tREFRESHED\tRUN\tSOURCE\tHASH\tINSERTED\tUPDATED\tUNCHANGED\tSKIPPED\tDELETED")
+	for _, r := range refreshes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			tableLabel(r.TableStats), formatTime(&r.RefreshedAt), shortID(r.RunID), r.SourceFile, shortID(r.ContentHash),
+			r.Inserted, r.Updated, r.Unchanged, r.Skipped, r.Deleted)
+	}
+	return w.Flush()
+}
+
+func printRuns(ctx context.Context, application *app.Application, limit int) error {
+	runs, err := application.LedgerService.Runs(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tKIND\tSOURCE\tSTATUS\tSTARTED\tFINISHED\tTABLES\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			run.ID, run.Kind, run.SourceFile, run.Status, formatTime(&run.StartedAt), formatTime(run.FinishedAt), len(run.Tables), run.Error)
+	}
+	return w.Flush()
+}
+
+func printVersions(ctx context.Context, application *app.Application) error {
+	versions, err := application.QueryService.TableVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tVERSION\tACTIVE\tNAME\tTITLE\tVALID FROM\tVALID TO\tROWS")
+	for _, tv := range versions {
+		active := ""
+		if tv.Active {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			tv.TableCode, tv.Version, active, tv.Table, tv.Title, formatDate(tv.ValidFrom), formatDate(tv.ValidTo), tv.Rows)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/lantoniomiranda/shitreader/internal/app"
+	"github.com/lantoniomiranda/shitreader/internal/manifest"
+	"github.com/lantoniomiranda/shitreader/internal/services"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+const defaultManifest = "manifest.json"
+
+func sourceTask(application *app.Application, src manifest.Source, opts services.ReadOptions) task {
+	t := sourceRunner(application, src, opts)
+	t.id = src.Name
+	t.deps = src.DependsOn
+	return t
+}
+
+func sourceRunner(application *app.Application, src manifest.Source, opts services.ReadOptions) task {
+	switch src.Kind {
+	case manifest.KindBlockCatalog:
+		return task{
+			name: fmt.Sprintf("Import %s", filepath.Base(src.Path)),
+			run: func(ctx context.Context) (*types.ImportRun, error) {
+				validFrom, err := src.ValidFromDate()
+				if err != nil {
+					return nil, err
+				}
+				opts.ValidFrom = validFrom
+				return application.ReaderService.Read(ctx, src.File(), opts)
+			},
+		}
+	case manifest.KindProcessSteps:
+		return task{
+			name: fmt.Sprintf("Inspect %s", filepath.Base(src.Path)),
+			run: func(ctx context.Context) (*types.ImportRun, error) {
+				return application.ReaderService.ReadProcessSteps(ctx, src.File(), opts.Versions)
+			},
+		}
+	case manifest.KindRecordFields:
+		return task{
+			name: "Associate fields with records",
+			run: func(ctx context.Context) (*types.ImportRun, error) {
+				return application.AssociationService.Associate(ctx, opts.Versions)
+			},
+		}
+	case manifest.KindRecordTypes:
+		return task{
+			name: "Associate record types",
+			run: func(ctx context.Context) (*types.ImportRun, error) {
+				return application.AssociationService.AssociateRecordTypes(ctx, src.File(), opts.Versions)
+			},
+		}
+	default:
+		return task{
+			name: "Associate steps",
+			run: func(ctx context.Context) (*types.ImportRun, error) {
+				return application.AssociationService.AssociateSteps(ctx, src.File(), opts.Versions)
+			},
+		}
+	}
+}
+
+func matchesFile(src manifest.Source, file string) bool {
+	if src.Path == "" {
+		return false
+	}
+	return filepath.Clean(src.Path) == filepath.Clean(file) || filepath.Base(src.Path) == file
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+func runValidity(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validity", flag.ExitOnError)
+	from := fs.String("from", "", "date the version applies from, as YYYY-MM-DD")
+	to := fs.String("to", "", "date the version stops applying, as YYYY-MM-DD (default: until a later version starts)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || *from == "" {
+		return fmt.Errorf("usage: validity <table-code> <version> --from YYYY-MM-DD [--to YYYY-MM-DD]")
+	}
+
+	fromDate, err := parseDate("--from", *from)
+	if err != nil {
+		return err
+	}
+	var toDate *time.Time
+	if *to != "" {
+		date, err := parseDate("--to", *to)
+		if err != nil {
+			return err
+		}
+		toDate = &date
+	}
+
+	application, err := openApplication(ctx)
+	if err != nil {
+		return err
+	}
+	defer application.DB.Close()
+
+	return application.VersionService.SetValidity(ctx, positional[0], positional[1], fromDate, toDate)
+}
+
+func parseDate(flag string, value string) (time.Time, error) {
+	date, err := time.Parse(types.DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD", flag, value)
+	}
+	return date, nil
+}
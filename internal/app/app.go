@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -12,33 +13,52 @@ import (
 type Application struct {
 	ReaderService      *services.ReaderService
 	AssociationService *services.AssociationService
+	QueryService       *services.QueryService
+	LedgerService      *services.LedgerService
+	VersionService     *services.VersionService
+	RollbackService    *services.RollbackService
+	Transactions       *store.TxManager
 	DB                 *sql.DB
 }
 
-func NewApplication() (*Application, error) {
-	pgDb, err := store.Open()
+func NewApplication(ctx context.Context) (*Application, error) {
+	pgDb, err := store.Open(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to open database: %w", err)
 	}
 
-	if err := pgDb.Ping(); err != nil {
+	if err := pgDb.PingContext(ctx); err != nil {
 		return nil, fmt.Errorf("Failed to ping database: %w", err)
 	}
 
-	err = store.MigrateFS(pgDb, migrations.FS, ".")
+	err = store.MigrateFS(ctx, pgDb, migrations.FS, ".")
 	if err != nil {
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
 
-	entryStore := store.NewPostgresEntryStore(pgDb)
-	associationStore := store.NewPostgresAssociationStore(pgDb)
+	txManager := store.NewTxManager(pgDb)
+	entryStore := store.NewPostgresEntryStore(txManager)
+	associationStore := store.NewPostgresAssociationStore(txManager)
+	queryStore := store.NewPostgresQueryStore(pgDb)
+	runStore := store.NewPostgresRunStore(pgDb)
+	versionStore := store.NewPostgresVersionStore(pgDb)
+	rollbackStore := store.NewPostgresRollbackStore(txManager)
 
-	readerService := services.NewReaderService(entryStore)
-	associationService := services.NewAssociationService(associationStore)
+	readerService := services.NewReaderService(entryStore, runStore)
+	associationService := services.NewAssociationService(associationStore, runStore)
+	queryService := services.NewQueryService(queryStore)
+	ledgerService := services.NewLedgerService(runStore)
+	versionService := services.NewVersionService(versionStore)
+	rollbackService := services.NewRollbackService(rollbackStore, runStore)
 
 	return &Application{
 		ReaderService:      readerService,
 		AssociationService: associationService,
+		QueryService:       queryService,
+		LedgerService:      ledgerService,
+		VersionService:     versionService,
+		RollbackService:    rollbackService,
+		Transactions:       txManager,
 		DB:                 pgDb,
 	}, nil
 }
@@ -0,0 +1,141 @@
+package block
+
+import (
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/source"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+// Columns of a block-format sheet.
+const (
+	ColumnTable   = 1
+	ColumnVersion = 2
+	ColumnCode    = 3
+	ColumnTitle   = 4
+)
+
+// Header opens a block: the table code in A, an optional version in B, an
+// empty C and an optional title in D.
+type Header struct {
+	TableCode string
+	Version   string
+	Title     string
+	Line      int
+}
+
+// Parser reads a sheet made of blocks, each a header row followed by data
+// rows that repeat the header's table code in A and carry a code in C:
+//
+//	T10110  V01.00          Países
+//	T10110  V01.00  Portugal  PT
+//
+// Several versions of a table follow each other as blocks of their own.
+//
+// Rows that do not fit are skipped and recorded as errors located at the
+// offending cell, instead of being read as something else.
+type Parser struct {
+	file   source.File
+	rows   source.Reader
+	header *Header
+	isHead bool
+	errors []types.RowError
+}
+
+func NewParser(file source.File, rows source.Reader) *Parser {
+	return &Parser{
+		file: file,
+		rows: rows,
+	}
+}
+
+// Next advances to the next header or data row.
+func (p *Parser) Next() bool {
+	for p.rows.Next() {
+		row := p.rows.Row()
+		line := p.rows.Line()
+		tableCode := cell(row, ColumnTable)
+
+		if tableCode == "" {
+			p.fail(line, ColumnTable, "missing table code")
+			continue
+		}
+
+		if cell(row, ColumnCode) == "" {
+			// A row of the current table without a code is a data row with a
+			// blank code, unless it names another version: consecutive
+			// versions of a table each have their own header.
+			version := cell(row, ColumnVersion)
+			if p.header != nil && tableCode == p.header.TableCode && (version == "" || version == p.header.Version) {
+				p.fail(line, ColumnCode, fmt.Sprintf("missing code in a data row of %s", tableCode))
+				continue
+			}
+			p.header = &Header{
+				TableCode: tableCode,
+				Version:   cell(row, ColumnVersion),
+				Title:     cell(row, ColumnTitle),
+				Line:      line,
+			}
+			p.isHead = true
+			return true
+		}
+
+		switch {
+		case p.header == nil:
+			p.fail(line, ColumnTable, fmt.Sprintf("data row for %s before any table header", tableCode))
+			continue
+		case tableCode != p.header.TableCode:
+			p.fail(line, ColumnTable, fmt.Sprintf("data row for %s inside the block of %s (header at row %d)",
+				tableCode, p.header.TableCode, p.header.Line))
+			continue
+		}
+
+		p.isHead = false
+		return true
+	}
+	return false
+}
+
+// Header returns the header of the current block.
+func (p *Parser) Header() *Header {
+	return p.header
+}
+
+// IsHeader reports whether the current row is the block header itself.
+func (p *Parser) IsHeader() bool {
+	return p.isHead
+}
+
+func (p *Parser) Row() []string {
+	return p.rows.Row()
+}
+
+func (p *Parser) Line() int {
+	return p.rows.Line()
+}
+
+func (p *Parser) Numeric(column int) bool {
+	return p.rows.Numeric(column)
+}
+
+func (p *Parser) Errors() []types.RowError {
+	return p.errors
+}
+
+func (p *Parser) Err() error {
+	return p.rows.Err()
+}
+
+func (p *Parser) fail(line int, column int, message string) {
+	p.errors = append(p.errors, types.RowError{
+		Location: p.file.At(line, column).String(),
+		Message:  message,
+	})
+}
+
+func cell(row []string, column int) string {
+	if column-1 < len(row) {
+		return row[column-1]
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package block
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lantoniomiranda/shitreader/internal/source"
+)
+
+// rowReader serves rows from memory, numbered from 1.
+type rowReader struct {
+	rows [][]string
+	line int
+}
+
+func (r *rowReader) Next() bool {
+	if r.line >= len(r.rows) {
+		return false
+	}
+	r.line++
+	return true
+}
+
+func (r *rowReader) Row() []string           { return r.rows[r.line-1] }
+func (r *rowReader) Line() int               { return r.line }
+func (r *rowReader) Numeric(column int) bool { return false }
+func (r *rowReader) Err() error              { return nil }
+func (r *rowReader) Close() error            { return nil }
+
+// parsed is a row the parser returned: the header it belongs to and, for data
+// rows, its code.
+type parsed struct {
+	line    int
+	table   string
+	version string
+	code    string
+}
+
+func parse(t *testing.T, rows [][]string) ([]parsed, []string) {
+	t.Helper()
+	p := NewParser(source.File{Path: "tabelas.xlsx", Sheet: "Data"}, &rowReader{rows: rows})
+
+	var got []parsed
+	for p.Next() {
+		r := parsed{line: p.Line(), table: p.Header().TableCode, version: p.Header().Version}
+		if !p.IsHeader() {
+			r.code = cell(p.Row(), ColumnCode)
+		}
+		got = append(got, r)
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var errors []string
+	for _, e := range p.Errors() {
+		errors = append(errors, e.Location+": "+e.Message)
+	}
+	return got, errors
+}
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [][]string
+		want   []parsed
+		errors []string
+	}{
+		{
+			name: "blocks of different tables",
+			rows: [][]string{
+				{"T10110", "V01.00", "", "Países"},
+				{"T10110", "V01.00", "PT", "Portugal"},
+				{"T10120", "V01.00", "", "Distritos"},
+				{"T10120", "V01.00", "01", "Aveiro"},
+			},
+			want: []parsed{
+				{1, "T10110", "V01.00", ""},
+				{2, "T10110", "V01.00", "PT"},
+				{3, "T10120", "V01.00", ""},
+				{4, "T10120", "V01.00", "01"},
+			},
+		},
+		{
+			name: "consecutive versions of a table",
+			rows: [][]string{
+				{"T12510", "V01.00", "", "Potências"},
+				{"T12510", "V01.00", "EDE110", "1,15kVA"},
+				{"T12510", "V02.00", "", "Potências"},
+				{"T12510", "V02.00", "EDE110", "1,15 kVA"},
+			},
+			want: []parsed{
+				{1, "T12510", "V01.00", ""},
+				{2, "T12510", "V01.00", "EDE110"},
+				{3, "T12510", "V02.00", ""},
+				{4, "T12510", "V02.00", "EDE110"},
+			},
+		},
+		{
+			name: "blank code",
+			rows: [][]string{
+				{"T12510", "V01.00", "", "Potências"},
+				{"T12510", "V01.00", "", "1,15kVA"},
+				{"T12510", "", "", "3,45kVA"},
+				{"T12510", "V01.00", "EDE345", "3,45kVA"},
+			},
+			want: []parsed{
+				{1, "T12510", "V01.00", ""},
+				{4, "T12510", "V01.00", "EDE345"},
+			},
+			errors: []string{
+				"tabelas.xlsx!Data!C2: missing code in a data row of T12510",
+				"tabelas.xlsx!Data!C3: missing code in a data row of T12510",
+			},
+		},
+		{
+			name: "row before any header",
+			rows: [][]string{
+				{"T12510", "V01.00", "EDE110", "1,15kVA"},
+				{"T12510", "V01.00", "", "Potências"},
+				{"T12510", "V01.00", "EDE345", "3,45kVA"},
+			},
+			want: []parsed{
+				{2, "T12510", "V01.00", ""},
+				{3, "T12510", "V01.00", "EDE345"},
+			},
+			errors: []string{
+				"tabelas.xlsx!Data!A1: data row for T12510 before any table header",
+			},
+		},
+		{
+			name: "foreign table code inside a block",
+			rows: [][]string{
+				{"T10150", "V01.00", "", "Zonas"},
+				{"T10150", "V01.00", "1317RS", "Residual"},
+				{"T10151", "V01.01", "036747", "Lapas"},
+				{"T10150", "V01.00", "0115RS", "Residual"},
+			},
+			want: []parsed{
+				{1, "T10150", "V01.00", ""},
+				{2, "T10150", "V01.00", "1317RS"},
+				{4, "T10150", "V01.00", "0115RS"},
+			},
+			errors: []string{
+				"tabelas.xlsx!Data!A3: data row for T10151 inside the block of T10150 (header at row 1)",
+			},
+		},
+		{
+			name: "missing table code",
+			rows: [][]string{
+				{"T12510", "V01.00", "", "Potências"},
+				{"", "", "EDE110", "1,15kVA"},
+			},
+			want: []parsed{
+				{1, "T12510", "V01.00", ""},
+			},
+			errors: []string{
+				"tabelas.xlsx!Data!A2: missing table code",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errors := parse(t, tt.rows)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(errors, tt.errors) {
+				t.Errorf("errors = %q, want %q", errors, tt.errors)
+			}
+		})
+	}
+}
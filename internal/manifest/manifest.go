@@ -0,0 +1,227 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/source"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type Kind string
+
+const (
+	KindBlockCatalog Kind = "block-catalog"
+	KindProcessSteps Kind = "process-steps"
+	KindRecordFields Kind = "record-fields"
+	KindRecordTypes  Kind = "record-types"
+	KindStepRecords  Kind = "step-records"
+)
+
+const defaultSheet = "Data"
+
+type Source struct {
+	Name      string        `json:"name"`
+	Path      string        `json:"path,omitempty"`
+	Sheet     string        `json:"sheet,omitempty"`
+	Format    source.Format `json:"format,omitempty"`
+	Kind      Kind          `json:"kind"`
+	DependsOn []string      `json:"depends_on,omitempty"`
+	// ValidFrom is the date, as YYYY-MM-DD, the table versions of a
+	// block-catalog source apply from.
+	ValidFrom string `json:"valid_from,omitempty"`
+}
+
+type Manifest struct {
+	Sources []Source `json:"sources"`
+}
+
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range m.Sources {
+		src := &m.Sources[i]
+		if src.Path != "" && !filepath.IsAbs(src.Path) {
+			src.Path = filepath.Join(dir, src.Path)
+		}
+		src.SetDefaults()
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	return &m, nil
+}
+
+// SetDefaults fills in the sheet of workbook sources. Delimited sources have
+// no sheets.
+func (s *Source) SetDefaults() {
+	if s.Sheet == "" && s.File().Format == source.FormatXLSX {
+		s.Sheet = defaultSheet
+	}
+}
+
+// ValidFromDate returns the parsed ValidFrom, or nil when it is not set.
+func (s Source) ValidFromDate() (*time.Time, error) {
+	if s.ValidFrom == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(types.DateLayout, s.ValidFrom)
+	if err != nil {
+		return nil, fmt.Errorf("source %q has invalid valid_from %q, expected YYYY-MM-DD", s.Name, s.ValidFrom)
+	}
+	return &date, nil
+}
+
+// File returns the row source of s, with the format detected from the path
+// when the manifest does not set it.
+func (s Source) File() source.File {
+	format := s.Format
+	if format == "" && s.Path != "" {
+		format, _ = source.DetectFormat(s.Path)
+	}
+	return source.File{Path: s.Path, Sheet: s.Sheet, Format: format}
+}
+
+func (k Kind) NeedsFile() bool {
+	return k != KindRecordFields
+}
+
+func (k Kind) IsImport() bool {
+	return k == KindBlockCatalog || k == KindProcessSteps
+}
+
+func (k Kind) valid() bool {
+	switch k {
+	case KindBlockCatalog, KindProcessSteps, KindRecordFields, KindRecordTypes, KindStepRecords:
+		return true
+	}
+	return false
+}
+
+func (m *Manifest) Validate() error {
+	names := make(map[string]bool, len(m.Sources))
+	for _, src := range m.Sources {
+		if src.Name == "" {
+			return fmt.Errorf("source without a name")
+		}
+		if names[src.Name] {
+			return fmt.Errorf("duplicate source %q", src.Name)
+		}
+		names[src.Name] = true
+
+		if !src.Kind.valid() {
+			return fmt.Errorf("source %q has unknown kind %q", src.Name, src.Kind)
+		}
+		if src.Kind.NeedsFile() && src.Path == "" {
+			return fmt.Errorf("source %q of kind %s needs a path", src.Name, src.Kind)
+		}
+		if src.Format != "" && !src.Format.Valid() {
+			return fmt.Errorf("source %q has unknown format %q", src.Name, src.Format)
+		}
+		if src.ValidFrom != "" && src.Kind != KindBlockCatalog {
+			return fmt.Errorf("source %q of kind %s cannot have valid_from", src.Name, src.Kind)
+		}
+		if _, err := src.ValidFromDate(); err != nil {
+			return err
+		}
+		if src.Path != "" && src.Format == "" {
+			if _, err := source.DetectFormat(src.Path); err != nil {
+				return fmt.Errorf("source %q: %w, set its format", src.Name, err)
+			}
+		}
+	}
+
+	for _, src := range m.Sources {
+		for _, dep := range src.DependsOn {
+			if !names[dep] {
+				return fmt.Errorf("source %q depends on unknown source %q", src.Name, dep)
+			}
+		}
+	}
+
+	if _, err := order(m.Sources); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Manifest) Source(name string) (Source, bool) {
+	for _, src := range m.Sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+// Ordered returns the sources accepted by keep in dependency order, keeping
+// the manifest order between sources that do not depend on each other.
+// Dependencies on sources that keep rejects are treated as already satisfied.
+func (m *Manifest) Ordered(keep func(Source) bool) ([]Source, error) {
+	selected := make([]Source, 0, len(m.Sources))
+	for _, src := range m.Sources {
+		if keep == nil || keep(src) {
+			selected = append(selected, src)
+		}
+	}
+	return order(selected)
+}
+
+func order(sources []Source) ([]Source, error) {
+	index := make(map[string]int, len(sources))
+	for i, src := range sources {
+		index[src.Name] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(sources))
+	ordered := make([]Source, 0, len(sources))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle through source %q", sources[i].Name)
+		}
+		state[i] = visiting
+		for _, dep := range sources[i].DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		ordered = append(ordered, sources[i])
+		return nil
+	}
+
+	for i := range sources {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// LoadSlugs reads the catalog slugs of auto-registered tables, a JSON object
+// from table code to slug:
+//
+//	{ "T30010": "meter_models" }
+func LoadSlugs(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading slugs: %w", err)
+	}
+
+	var slugs map[string]string
+	if err := json.Unmarshal(data, &slugs); err != nil {
+		return nil, fmt.Errorf("error parsing slugs %s: %w", path, err)
+	}
+
+	declared := make(map[string]bool, len(types.TableCodeMap))
+	for _, name := range types.TableCodeMap {
+		declared[name] = true
+	}
+
+	used := make(map[string]string, len(slugs))
+	for code, slug := range slugs {
+		if _, ok := types.TableCodeMap[code]; ok {
+			return nil, fmt.Errorf("invalid slugs %s: %s is already mapped to %s", path, code, types.TableCodeMap[code])
+		}
+		if !slugPattern.MatchString(slug) {
+			return nil, fmt.Errorf("invalid slugs %s: %q for %s is not a slug", path, slug, code)
+		}
+		if declared[slug] {
+			return nil, fmt.Errorf("invalid slugs %s: %q for %s is the name of a declared table", path, slug, code)
+		}
+		if other, ok := used[slug]; ok {
+			return nil, fmt.Errorf("invalid slugs %s: %s and %s both use %q", path, other, code, slug)
+		}
+		used[slug] = code
+	}
+	return slugs, nil
+}
@@ -0,0 +1,143 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Rule string
+
+const (
+	NFC       Rule = "nfc"
+	Invisible Rule = "invisible"
+	Spaces    Rule = "spaces"
+	Trim      Rule = "trim"
+	Dashes    Rule = "dashes"
+)
+
+// Default is applied unless a source asks for something else. Dashes is left
+// out because some tables use them as values.
+var Default = []Rule{NFC, Invisible, Spaces, Trim}
+
+var all = []Rule{NFC, Invisible, Spaces, Trim, Dashes}
+
+// Normalizer applies its rules in a fixed order, whatever order they were
+// given in.
+type Normalizer struct {
+	rules map[Rule]bool
+}
+
+func New(rules []Rule) (*Normalizer, error) {
+	n := &Normalizer{rules: make(map[Rule]bool, len(rules))}
+	for _, r := range rules {
+		if !r.valid() {
+			return nil, fmt.Errorf("unknown normalization rule %q", r)
+		}
+		n.rules[r] = true
+	}
+	return n, nil
+}
+
+// Parse reads a comma-separated rule list. "default" stands for Default and
+// "none" turns normalization off.
+func Parse(list []string) ([]Rule, error) {
+	var rules []Rule
+	for _, name := range list {
+		switch name {
+		case "none":
+			return nil, nil
+		case "default":
+			rules = append(rules, Default...)
+		case "all":
+			rules = append(rules, all...)
+		default:
+			r := Rule(name)
+			if !r.valid() {
+				return nil, fmt.Errorf("unknown normalization rule %q", name)
+			}
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (r Rule) valid() bool {
+	for _, known := range all {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// String normalizes s and returns the rules that changed it.
+func (n *Normalizer) String(s string) (string, []Rule) {
+	if n == nil || len(n.rules) == 0 {
+		return s, nil
+	}
+
+	var applied []Rule
+	apply := func(rule Rule, f func(string) string) {
+		if !n.rules[rule] {
+			return
+		}
+		if out := f(s); out != s {
+			s = out
+			applied = append(applied, rule)
+		}
+	}
+
+	apply(NFC, norm.NFC.String)
+	apply(Invisible, stripInvisible)
+	apply(Dashes, unifyDashes)
+	apply(Spaces, collapseSpaces)
+	apply(Trim, strings.TrimSpace)
+	return s, applied
+}
+
+// stripInvisible removes format characters such as zero-width spaces, soft
+// hyphens and byte order marks, and control characters other than
+// whitespace.
+func stripInvisible(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) || (unicode.IsControl(r) && !unicode.IsSpace(r)) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func unifyDashes(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '-' && (unicode.Is(unicode.Pd, r) || r == '\u2212') {
+			return '-'
+		}
+		return r
+	}, s)
+}
+
+// collapseSpaces turns every run of whitespace, including non-breaking
+// spaces and line breaks, into a single space.
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package normalize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		list    []string
+		want    []Rule
+		wantErr bool
+	}{
+		{list: nil, want: nil},
+		{list: []string{"default"}, want: Default},
+		{list: []string{"all"}, want: []Rule{NFC, Invisible, Spaces, Trim, Dashes}},
+		{list: []string{"none"}, want: nil},
+		{list: []string{"trim", "none"}, want: nil},
+		{list: []string{"default", "dashes"}, want: []Rule{NFC, Invisible, Spaces, Trim, Dashes}},
+		{list: []string{"spaces", "trim"}, want: []Rule{Spaces, Trim}},
+		{list: []string{"trim", "upper"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestNewRejectsUnknownRules(t *testing.T) {
+	if _, err := New([]Rule{Trim, "upper"}); err == nil {
+		t.Error("New accepted an unknown rule")
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		in      string
+		want    string
+		applied []Rule
+	}{
+		{
+			name:  "unchanged",
+			rules: Default,
+			in:    "Anulação da Mudança",
+			want:  "Anulação da Mudança",
+		},
+		{
+			name:    "decomposed accents",
+			rules:   Default,
+			in:      "Anulac\u0327a\u0303o",
+			want:    "Anulação",
+			applied: []Rule{NFC},
+		},
+		{
+			name:    "zero-width space",
+			rules:   Default,
+			in:      "Estimada Provisória\u200b",
+			want:    "Estimada Provisória",
+			applied: []Rule{Invisible},
+		},
+		{
+			name:    "doubled spaces",
+			rules:   Default,
+			in:      "Anulação/Reposição  da Mudança",
+			want:    "Anulação/Reposição da Mudança",
+			applied: []Rule{Spaces},
+		},
+		{
+			name:    "trailing non-breaking space",
+			rules:   Default,
+			in:      "ZV\u00a0",
+			want:    "ZV",
+			applied: []Rule{Spaces, Trim},
+		},
+		{
+			name:    "invisible character between spaces",
+			rules:   Default,
+			in:      "Baixa \u00ad Tensão",
+			want:    "Baixa Tensão",
+			applied: []Rule{Invisible, Spaces},
+		},
+		{
+			name:  "dashes are off by default",
+			rules: Default,
+			in:    "BT \u2013 Baixa Tensão",
+			want:  "BT \u2013 Baixa Tensão",
+		},
+		{
+			name:    "dashes",
+			rules:   []Rule{Dashes},
+			in:      "BT \u2013 Baixa Tensão \u2014 1\u22122",
+			want:    "BT - Baixa Tensão - 1-2",
+			applied: []Rule{Dashes},
+		},
+		{
+			name:    "rules run in a fixed order whatever order they were given in",
+			rules:   []Rule{Trim, Spaces, Invisible},
+			in:      " A\u200b  \u200bB ",
+			want:    "A B",
+			applied: []Rule{Invisible, Spaces, Trim},
+		},
+		{
+			name:  "no rules",
+			rules: nil,
+			in:    " A  B ",
+			want:  " A  B ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, applied := n.String(tt.in)
+			if got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !slices.Equal(applied, tt.applied) {
+				t.Errorf("String(%q) applied %q, want %q", tt.in, applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestNilNormalizer(t *testing.T) {
+	var n *Normalizer
+	if got, applied := n.String(" A "); got != " A " || applied != nil {
+		t.Errorf("nil normalizer changed the value to %q with %q", got, applied)
+	}
+}
@@ -3,43 +3,163 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/lantoniomiranda/shitreader/internal/source"
 	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
 )
 
 type AssociationService struct {
 	associationStore store.AssociationStore
+	runStore         store.RunStore
 }
 
-func NewAssociationService(associtationStore store.AssociationStore) *AssociationService {
+func NewAssociationService(associtationStore store.AssociationStore, runStore store.RunStore) *AssociationService {
 	return &AssociationService{
 		associationStore: associtationStore,
+		runStore:         runStore,
 	}
 }
 
-func (s *AssociationService) Associate() error {
-	ctx := context.Background()
-	err := s.associationStore.AssociateRecordsFields(ctx)
+func (s *AssociationService) Associate(ctx context.Context, versions types.Versions) (*types.ImportRun, error) {
+	run, err := startRun(ctx, s.runStore, types.RunKindRecordFields, "", "", nil)
 	if err != nil {
-		return fmt.Errorf("error doing associations: %w", err)
+		return nil, err
 	}
-	return nil
+
+	stats, err := s.associationStore.AssociateRecordsFields(store.WithRun(ctx, run.ID), versions)
+	if err != nil {
+		err = fmt.Errorf("error doing associations: %w", err)
+	}
+	run.Tables = stats
+	return run, finishRun(ctx, s.runStore, run, err)
+}
+
+func (s *AssociationService) AssociateRecordTypes(ctx context.Context, file source.File, versions types.Versions) (*types.ImportRun, error) {
+	run, err := startRun(ctx, s.runStore, types.RunKindRecordTypes, file.Path, file.Sheet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := readRecordTypeLinks(file)
+	if err != nil {
+		return run, finishRun(ctx, s.runStore, run, err)
+	}
+
+	stats, err := s.associationStore.AssociateRecordsRecordTypes(store.WithRun(ctx, run.ID), links, versions)
+	if err != nil {
+		err = fmt.Errorf("error associating record types: %w", err)
+	}
+	run.Tables = stats
+	return run, finishRun(ctx, s.runStore, run, err)
+}
+
+func (s *AssociationService) AssociateSteps(ctx context.Context, file source.File, versions types.Versions) (*types.ImportRun, error) {
+	run, err := startRun(ctx, s.runStore, types.RunKindStepRecords, file.Path, file.Sheet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := readStepLinks(file)
+	if err != nil {
+		return run, finishRun(ctx, s.runStore, run, err)
+	}
+
+	stats, err := s.associationStore.AssociateStepsHeaderTypesAndRecords(store.WithRun(ctx, run.ID), steps, versions)
+	if err != nil {
+		err = fmt.Errorf("error associating steps: %w", err)
+	}
+	run.Tables = stats
+	return run, finishRun(ctx, s.runStore, run, err)
 }
 
-func (s *AssociationService) AssociateRecordTypes(filePath string, sheetName string) error {
-	ctx := context.Background()
-	err := s.associationStore.AssociateRecordsRecordTypes(ctx, filePath, sheetName)
+func readRecordTypeLinks(file source.File) ([]types.RecordTypeLink, error) {
+	rows, err := file.Open()
 	if err != nil {
-		return fmt.Errorf("error associating record types: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []types.RecordTypeLink
+	for header := true; rows.Next(); header = false {
+		row := rows.Row()
+		if header || len(row) < 3 {
+			continue
+		}
+		if row[1] == "" || row[2] == "" {
+			continue
+		}
+		links = append(links, types.RecordTypeLink{RecordCode: row[1], RecordTypeCode: row[2]})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return nil
+	return links, nil
 }
 
-func (s *AssociationService) AssociateSteps(filePath string, sheetName string) error {
-	ctx := context.Background()
-	err := s.associationStore.AssociateStepsHeaderTypesAndRecords(ctx, filePath, sheetName)
+// readStepLinks groups the record codes by step. Step and header type cells
+// are only filled on the first row of a step, so blanks repeat the last value.
+func readStepLinks(file source.File) ([]types.StepLinks, error) {
+	rows, err := file.Open()
 	if err != nil {
-		return fmt.Errorf("error associating steps: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []types.StepLinks
+	index := make(map[string]int)
+
+	var lastStepCode string
+	var lastHeaderTypeCode string
+
+	for header := true; rows.Next(); header = false {
+		row := rows.Row()
+		if header || len(row) < 3 {
+			continue
+		}
+
+		stepCode := row[0]
+		headerTypeCode := row[1]
+		recordCode := row[2]
+
+		if stepCode != "" {
+			lastStepCode = stepCode
+		} else {
+			stepCode = lastStepCode
+		}
+
+		if headerTypeCode != "" {
+			lastHeaderTypeCode = headerTypeCode
+		} else {
+			headerTypeCode = lastHeaderTypeCode
+		}
+
+		if stepCode == "" || headerTypeCode == "" || recordCode == "" {
+			continue
+		}
+
+		i, exists := index[stepCode]
+		if !exists {
+			i = len(steps)
+			index[stepCode] = i
+			steps = append(steps, types.StepLinks{StepCode: stepCode})
+		}
+		sd := &steps[i]
+
+		if len(sd.HeaderTypeCodes) == 0 {
+			for _, ht := range strings.Split(headerTypeCode, ",") {
+				trimmedHT := strings.TrimSpace(ht)
+				if trimmedHT != "" {
+					sd.HeaderTypeCodes = append(sd.HeaderTypeCodes, trimmedHT)
+				}
+			}
+		}
+
+		sd.RecordCodes = append(sd.RecordCodes, recordCode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return nil
+	return steps, nil
 }
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type LedgerService struct {
+	runStore store.RunStore
+}
+
+func NewLedgerService(runStore store.RunStore) *LedgerService {
+	return &LedgerService{
+		runStore: runStore,
+	}
+}
+
+func (s *LedgerService) Runs(ctx context.Context, limit int) ([]types.ImportRun, error) {
+	runs, err := s.runStore.ListRuns(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing import runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (s *LedgerService) Refreshes(ctx context.Context) ([]types.TableRefresh, error) {
+	refreshes, err := s.runStore.LatestRefreshes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing table refreshes: %w", err)
+	}
+	return refreshes, nil
+}
+
+func (s *LedgerService) Quarantined(ctx context.Context) ([]types.QuarantinedBlock, error) {
+	blocks, err := s.runStore.ListQuarantined(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing quarantined blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// MarkSucceeded records the pending runs of a committed pipeline as
+// succeeded.
+func (s *LedgerService) MarkSucceeded(ctx context.Context, runs []*types.ImportRun) error {
+	ctx = context.WithoutCancel(ctx)
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		if run.Status == types.RunStatusPending {
+			ids = append(ids, run.ID)
+			run.Status = types.RunStatusSucceeded
+		}
+	}
+	if err := s.runStore.MarkSucceeded(ctx, ids); err != nil {
+		return fmt.Errorf("error updating import runs: %w", err)
+	}
+	return nil
+}
+
+func (s *LedgerService) MarkRolledBack(ctx context.Context, runs []*types.ImportRun) error {
+	ctx = context.WithoutCancel(ctx)
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		if run.Status == types.RunStatusSucceeded || run.Status == types.RunStatusPending {
+			ids = append(ids, run.ID)
+			run.Status = types.RunStatusRolledBack
+		}
+	}
+	if err := s.runStore.MarkRolledBack(ctx, ids); err != nil {
+		return fmt.Errorf("error updating import runs: %w", err)
+	}
+	return nil
+}
+
+type pendingKey struct{}
+
+// WithPendingRuns makes the runs finished with the returned context record
+// their success as pending, for pipelines whose transaction is committed
+// after the runs finish. MarkSucceeded completes them once it is.
+func WithPendingRuns(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pendingKey{}, true)
+}
+
+func startRun(ctx context.Context, runStore store.RunStore, kind string, filePath string, sheetName string, tables []string) (*types.ImportRun, error) {
+	run := &types.ImportRun{
+		Kind:        kind,
+		SourceFile:  filePath,
+		Sheet:       sheetName,
+		TableFilter: strings.Join(tables, ","),
+	}
+
+	if filePath != "" {
+		hash, err := hashFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		run.ContentHash = hash
+	}
+
+	if err := runStore.StartRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func skipRun(ctx context.Context, runStore store.RunStore, run *types.ImportRun) error {
+	run.Status = types.RunStatusSkipped
+	return runStore.FinishRun(context.WithoutCancel(ctx), run)
+}
+
+// finishRun records the outcome of run. The ledger is written even when ctx
+// was cancelled, so an interrupted import still shows up as cancelled. A run
+// that succeeded inside an uncommitted pipeline is recorded as pending.
+func finishRun(ctx context.Context, runStore store.RunStore, run *types.ImportRun, runErr error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	run.Status = types.RunStatusSucceeded
+	if pending, _ := ctx.Value(pendingKey{}).(bool); pending {
+		run.Status = types.RunStatusPending
+	}
+	switch {
+	case errors.Is(runErr, context.Canceled), errors.Is(runErr, context.DeadlineExceeded):
+		run.Status = types.RunStatusCancelled
+		run.Error = runErr.Error()
+	case runErr != nil:
+		run.Status = types.RunStatusFailed
+		run.Error = runErr.Error()
+	}
+
+	if err := runStore.FinishRun(ctx, run); err != nil {
+		if runErr != nil {
+			return fmt.Errorf("%w (also failed to record run: %v)", runErr, err)
+		}
+		return err
+	}
+	return runErr
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("error hashing file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
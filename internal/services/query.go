@@ -0,0 +1,218 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type QueryService struct {
+	queryStore store.QueryStore
+}
+
+func NewQueryService(queryStore store.QueryStore) *QueryService {
+	return &QueryService{
+		queryStore: queryStore,
+	}
+}
+
+func (s *QueryService) TableVersions(ctx context.Context) ([]types.TableVersion, error) {
+	versions, err := s.queryStore.ListTableVersions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing table versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (s *QueryService) Values(ctx context.Context, tableCode string, version string) ([]types.Value, error) {
+	if version == "" {
+		current, err := s.queryStore.CurrentVersion(ctx, tableCode)
+		if err != nil {
+			return nil, err
+		}
+		version = current
+	}
+
+	values, err := s.queryStore.ListValues(ctx, tableCode, version)
+	if err != nil {
+		return nil, fmt.Errorf("error listing values: %w", err)
+	}
+	return values, nil
+}
+
+// CurrentVersion returns the version of a table read when none is asked for:
+// the promoted one, or the highest imported one.
+func (s *QueryService) CurrentVersion(ctx context.Context, tableCode string) (string, error) {
+	return s.queryStore.CurrentVersion(ctx, tableCode)
+}
+
+func (s *QueryService) VersionAsOf(ctx context.Context, tableCode string, date time.Time) (string, error) {
+	return s.queryStore.VersionAsOf(ctx, tableCode, date)
+}
+
+func (s *QueryService) Lookup(ctx context.Context, tableCode string, code string) ([]types.Value, error) {
+	values, err := s.queryStore.LookupValue(ctx, tableCode, code)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up value: %w", err)
+	}
+	return values, nil
+}
+
+func (s *QueryService) History(ctx context.Context, tableCode string, code string) ([]types.Change, error) {
+	changes, err := s.queryStore.History(ctx, tableCode, code)
+	if err != nil {
+		return nil, fmt.Errorf("error loading history: %w", err)
+	}
+	return changes, nil
+}
+
+// Diff compares two imported versions of a table by code. Both versions must
+// exist, so a typo is not reported as every code having been removed.
+func (s *QueryService) Diff(ctx context.Context, tableCode string, fromVersion string, toVersion string) (*types.Diff, error) {
+	for _, version := range []string{fromVersion, toVersion} {
+		exists, err := s.queryStore.VersionExists(ctx, tableCode, version)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s %s has not been imported", tableCode, version)
+		}
+	}
+
+	from, err := s.queryStore.ListValues(ctx, tableCode, fromVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, fromVersion, err)
+	}
+	to, err := s.queryStore.ListValues(ctx, tableCode, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, toVersion, err)
+	}
+
+	diff := &types.Diff{
+		TableCode:   tableCode,
+		Table:       types.TableCodeMap[tableCode],
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+	}
+
+	fromMap := make(map[string]types.Value, len(from))
+	for _, v := range from {
+		fromMap[v.Code] = v
+	}
+	toMap := make(map[string]types.Value, len(to))
+	for _, v := range to {
+		toMap[v.Code] = v
+	}
+
+	diff.Added = []types.Value{}
+	diff.Removed = []types.Value{}
+	diff.Changed = []types.ValueChange{}
+	fromColumns, err := s.queryStore.ListColumns(ctx, tableCode, fromVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, fromVersion, err)
+	}
+	toColumns, err := s.queryStore.ListColumns(ctx, tableCode, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, toVersion, err)
+	}
+
+	for _, v := range to {
+		old, exists := fromMap[v.Code]
+		if !exists {
+			diff.Added = append(diff.Added, v)
+			continue
+		}
+		if old.Description != v.Description {
+			diff.Changed = append(diff.Changed, types.ValueChange{Code: v.Code, From: old.Description, To: v.Description})
+		}
+		for _, column := range slices.Sorted(maps.Keys(toColumns[v.Code])) {
+			before, after := fromColumns[v.Code][column], toColumns[v.Code][column]
+			if before != after {
+				diff.Changed = append(diff.Changed, types.ValueChange{Code: v.Code, Column: column, From: before, To: after})
+			}
+		}
+	}
+	for _, v := range from {
+		if _, exists := toMap[v.Code]; !exists {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+
+	fromLinks, err := s.queryStore.ListLinks(ctx, tableCode, fromVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, fromVersion, err)
+	}
+	toLinks, err := s.queryStore.ListLinks(ctx, tableCode, toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s %s: %w", tableCode, toVersion, err)
+	}
+	diff.Links = diffLinks(fromLinks, toLinks, func(code string) bool {
+		_, inFrom := fromMap[code]
+		_, inTo := toMap[code]
+		return inFrom && inTo
+	})
+
+	return diff, nil
+}
+
+// diffLinks compares the links of the codes in both versions. Added and
+// removed codes are reported with their values, not their links.
+func diffLinks(from []types.Link, to []types.Link, inBoth func(code string) bool) []types.LinkChange {
+	type linkKey struct{ code, kind string }
+	targets := func(links []types.Link) map[linkKey][]string {
+		m := make(map[linkKey][]string)
+		for _, l := range links {
+			if inBoth(l.Code) {
+				key := linkKey{l.Code, l.Kind}
+				m[key] = append(m[key], l.Target)
+			}
+		}
+		return m
+	}
+	before, after := targets(from), targets(to)
+
+	keys := make([]linkKey, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b linkKey) int {
+		return cmp.Or(cmp.Compare(a.code, b.code), cmp.Compare(a.kind, b.kind))
+	})
+
+	changes := []types.LinkChange{}
+	for _, key := range keys {
+		change := types.LinkChange{
+			Code:    key.code,
+			Link:    key.kind,
+			Added:   missingFrom(after[key], before[key]),
+			Removed: missingFrom(before[key], after[key]),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// missingFrom returns the sorted targets that are in list but not in other.
+func missingFrom(list []string, other []string) []string {
+	missing := []string{}
+	for _, target := range list {
+		if !slices.Contains(other, target) && !slices.Contains(missing, target) {
+			missing = append(missing, target)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
@@ -3,39 +3,149 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/catalog"
+	"github.com/lantoniomiranda/shitreader/internal/block"
+	"github.com/lantoniomiranda/shitreader/internal/normalize"
+	"github.com/lantoniomiranda/shitreader/internal/source"
 	"github.com/lantoniomiranda/shitreader/internal/store"
 	"github.com/lantoniomiranda/shitreader/internal/types"
-	"github.com/xuri/excelize/v2"
 )
 
 type ReaderService struct {
 	entryStore store.EntryStore
+	runStore   store.RunStore
 }
 
-func NewReaderService(entryStore store.EntryStore) *ReaderService {
+func NewReaderService(entryStore store.EntryStore, runStore store.RunStore) *ReaderService {
 	return &ReaderService{
 		entryStore: entryStore,
+		runStore:   runStore,
 	}
 }
 
 const flushThreshold = 500
 
-func (s *ReaderService) Read(filePath string, sheetName string) error {
-	ctx := context.Background()
+type ReadOptions struct {
+	Tables    []string
+	Force     bool
+	Strict    bool
+	Normalize []normalize.Rule
+	// AutoRegister stores blocks with unknown table codes as catalogs named
+	// after the code, or after its entry in Slugs, instead of quarantining
+	// them.
+	AutoRegister bool
+	Slugs        map[string]string
+	// Versions pins the table versions process steps and associations link
+	// rows of.
+	Versions types.Versions
+	// ValidFrom is the date the table versions of the source apply from.
+	ValidFrom *time.Time
+	// Protect lists, by table code, codes that are kept even when they are
+	// missing from the source, such as rows added by hand.
+	Protect map[string][]string
+}
+
+func (o ReadOptions) includes(tableCode string) bool {
+	if len(o.Tables) == 0 {
+		return true
+	}
+	for _, t := range o.Tables {
+		if t == tableCode {
+			return true
+		}
+	}
+	return false
+}
+
+// key lists the options that change what an import writes, so an unchanged
+// workbook is only skipped when it is read the same way as last time.
+func (o ReadOptions) key() string {
+	rules := make([]string, len(o.Normalize))
+	for i, r := range o.Normalize {
+		rules[i] = string(r)
+	}
+	slices.Sort(rules)
+	parts := []string{"normalize=" + strings.Join(slices.Compact(rules), ",")}
 
-	file, err := excelize.OpenFile(filePath)
+	if o.Strict {
+		parts = append(parts, "strict")
+	}
+	if o.AutoRegister {
+		parts = append(parts, "auto-register")
+	}
+	if len(o.Slugs) > 0 {
+		parts = append(parts, "slugs="+joinPairs(o.Slugs))
+	}
+	if len(o.Protect) > 0 {
+		protect := make(map[string]string, len(o.Protect))
+		for tableCode, codes := range o.Protect {
+			codes = slices.Sorted(slices.Values(codes))
+			protect[tableCode] = strings.Join(codes, "|")
+		}
+		parts = append(parts, "protect="+joinPairs(protect))
+	}
+	if o.ValidFrom != nil {
+		parts = append(parts, "valid-from="+o.ValidFrom.Format(types.DateLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+":"+v)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (o ReadOptions) slug(tableCode string) string {
+	if slug, ok := o.Slugs[tableCode]; ok {
+		return slug
+	}
+	return tableCode
+}
+
+func (s *ReaderService) Read(ctx context.Context, file source.File, opts ReadOptions) (*types.ImportRun, error) {
+
+	run, err := startRun(ctx, s.runStore, types.RunKindBlockCatalog, file.Path, file.Sheet, opts.Tables)
 	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	rows, err := file.GetRows(sheetName)
+	run.Options = opts.key()
+	if !opts.Force && len(opts.Tables) == 0 {
+		lastHash, err := s.runStore.LastImportedHash(ctx, run)
+		if err != nil {
+			return run, finishRun(ctx, s.runStore, run, err)
+		}
+		if lastHash == run.ContentHash {
+			return run, skipRun(ctx, s.runStore, run)
+		}
+	}
+
+	err = s.read(store.WithRun(ctx, run.ID), run, file, opts)
+	return run, finishRun(ctx, s.runStore, run, err)
+}
+
+func (s *ReaderService) read(ctx context.Context, run *types.ImportRun, file source.File, opts ReadOptions) error {
+	normalizer, err := normalize.New(opts.Normalize)
 	if err != nil {
-		return fmt.Errorf("error getting rows: %w", err)
+		return err
 	}
 
+	rows, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
 	tx, err := s.entryStore.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %w", err)
@@ -46,25 +156,149 @@ func (s *ReaderService) Read(filePath string, sheetName string) error {
 	var tableName string
 	var pendingEntries []types.Entry
 	var pendingTable string
+	var pendingCode string
+
+	saveBatch := func() error {
+		stats, err := s.entryStore.SaveBatch(ctx, tx, pendingEntries, pendingTable)
+		if err != nil {
+			return fmt.Errorf("error saving batch for %s after %d rows: %w", pendingTable, processedRows, err)
+		}
+		run.Stats(pendingCode, pendingTable).Add(stats)
+		processedRows += len(pendingEntries)
+		pendingEntries = pendingEntries[:0]
+		return nil
+	}
+
+	// seen collects the codes read for each table version, to soft-delete
+	// the rows that are no longer in the source once every row is saved.
+	type tableVersion struct{ table, code, version string }
+	seen := make(map[tableVersion][]string)
+	var seenOrder []tableVersion
+
+	// incomplete counts, by table code, the rows the parser rejected inside
+	// the table's blocks. Their codes are unknown, so those tables are not
+	// reconciled: the rows they would match are still in the source.
+	type rejectedRows struct{ rows, headerLine int }
+	incomplete := make(map[string]rejectedRows)
+	rejected := 0
+
+	// Every row is stored under its own version, or its header's when it has
+	// none. blockVersions holds the versions of the current block whose
+	// header has been saved; rows that disagree with the header are reported
+	// once per block.
+	var header *block.Header
+	var blockVersions map[string]bool
+	var mismatch struct {
+		rows      int
+		firstLine int
+		first     string
+		versions  map[string]bool
+	}
+	warnVersions := func() {
+		if mismatch.rows == 0 {
+			return
+		}
+		run.Warnings = append(run.Warnings, types.RowError{
+			Location: file.At(mismatch.firstLine, block.ColumnVersion).String(),
+			Message: fmt.Sprintf("%d rows of %s carry a version other than its header's %s (%s first, %d versions in all), each is stored under its own version",
+				mismatch.rows, header.TableCode, header.Version, mismatch.first, len(mismatch.versions)),
+		})
+		mismatch.rows = 0
+		mismatch.versions = nil
+	}
+
+	var quarantine *types.QuarantinedBlock
+	saveQuarantine := func() error {
+		if quarantine == nil {
+			return nil
+		}
+		if err := s.entryStore.SaveQuarantine(ctx, tx, run.ID, *quarantine); err != nil {
+			return err
+		}
+		run.Quarantined = append(run.Quarantined, *quarantine)
+		quarantine = nil
+		return nil
+	}
+
+	parser := block.NewParser(file, rows)
+	countRejected := func() {
+		n := len(parser.Errors())
+		if n > rejected && header != nil {
+			r := incomplete[header.TableCode]
+			if r.rows == 0 {
+				r.headerLine = header.Line
+			}
+			r.rows += n - rejected
+			incomplete[header.TableCode] = r
+		}
+		rejected = n
+	}
+	for parser.Next() {
+		// Rows rejected on the way to this one belong to the block before it.
+		countRejected()
+		row := parser.Row()
+		line := parser.Line()
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("import stopped at row %d after %d rows, rolling back: %w", line, processedRows, err)
+		}
 
-	for _, row := range rows {
-		isHeaderRow := len(row) >= 2 && (len(row) == 2 || (len(row) > 2 && row[2] == ""))
+		if parser.IsHeader() {
+			warnVersions()
+			h := *parser.Header()
+			header = &h
+			blockVersions = make(map[string]bool)
 
-		if isHeaderRow {
 			if len(pendingEntries) > 0 {
-				if err := s.entryStore.SaveBatch(ctx, tx, pendingEntries, pendingTable); err != nil {
-					return fmt.Errorf("error saving batch: %w", err)
+				if err := saveBatch(); err != nil {
+					return err
 				}
-				processedRows += len(pendingEntries)
-				pendingEntries = pendingEntries[:0]
+			}
+			if err := saveQuarantine(); err != nil {
+				return err
 			}
 
-			if t, ok := types.TableCodeMap[row[0]]; ok {
+			t, known := types.TableCodeMap[header.TableCode]
+			switch {
+			case known && opts.includes(header.TableCode):
 				tableName = t
-			} else {
+			case !known && opts.AutoRegister && opts.includes(header.TableCode):
+				tableName = opts.slug(header.TableCode)
+			case !known:
+				tableName = "OTHER"
+				quarantine = &types.QuarantinedBlock{
+					TableCode:   header.TableCode,
+					Version:     header.Version,
+					Description: header.Title,
+					SourceFile:  file.Path,
+					Sheet:       file.Sheet,
+					FirstRow:    line,
+					LastRow:     line,
+				}
+			default:
 				tableName = "OTHER"
 			}
 			pendingTable = tableName
+			pendingCode = header.TableCode
+
+			// The title is stored like any other cell, so it is normalized
+			// the same way.
+			if tableName != "OTHER" {
+				if title, rules := normalizer.String(header.Title); len(rules) > 0 {
+					run.TextFixes = append(run.TextFixes, types.TextFix{
+						Location:   file.At(line, block.ColumnTitle).String(),
+						Rules:      joinRules(rules),
+						Original:   header.Title,
+						Normalized: title,
+					})
+					header.Title = title
+				}
+			}
+			continue
+		}
+
+		if quarantine != nil {
+			quarantine.Rows = append(quarantine.Rows, row)
+			quarantine.LastRow = line
 			continue
 		}
 
@@ -72,23 +306,120 @@ func (s *ReaderService) Read(filePath string, sheetName string) error {
 			continue
 		}
 
-		entry := parseRow(row, tableName)
+		spec := types.SpecFor(tableName)
+		entry := spec.ParseEntry(row)
+		if entry.Version == "" {
+			entry.Version = header.Version
+		}
+		if header.Version != "" && entry.Version != header.Version {
+			if mismatch.rows == 0 {
+				mismatch.firstLine = line
+				mismatch.first = entry.Version
+				mismatch.versions = make(map[string]bool)
+			}
+			mismatch.rows++
+			mismatch.versions[entry.Version] = true
+		}
+		if !blockVersions[entry.Version] {
+			// The header is saved with the first row of each version, so only
+			// versions that rows are stored under get created and titled.
+			if err := s.entryStore.SaveHeader(ctx, tx, tableName, types.TableHeader{
+				TableCode: header.TableCode,
+				Version:   entry.Version,
+				Title:     header.Title,
+				ValidFrom: opts.ValidFrom,
+			}); err != nil {
+				return err
+			}
+			blockVersions[entry.Version] = true
+		}
+		for _, c := range spec.Columns {
+			value, rules := normalizer.String(entry.Values[c.Name])
+			if len(rules) == 0 {
+				continue
+			}
+			run.TextFixes = append(run.TextFixes, types.TextFix{
+				Location:   file.At(line, c.Source).String(),
+				Rules:      joinRules(rules),
+				Original:   entry.Values[c.Name],
+				Normalized: value,
+			})
+			entry.Values[c.Name] = value
+		}
+		for _, c := range spec.Columns {
+			if c.Width == 0 {
+				continue
+			}
+			location := file.At(line, c.Source).String()
+			value := entry.Values[c.Name]
+			if parser.Numeric(c.Source) {
+				run.Warnings = append(run.Warnings, types.RowError{
+					Location: location,
+					Message:  fmt.Sprintf("%s %q was stored as a number", c.Name, value),
+				})
+			}
+			if padded := padCode(value, c.Width); padded != value {
+				run.TextFixes = append(run.TextFixes, types.TextFix{
+					Location:   location,
+					Rules:      "width",
+					Original:   value,
+					Normalized: padded,
+				})
+				entry.Values[c.Name] = padded
+			}
+		}
+		// The code is kept as it is saved, after normalization and padding,
+		// or the row would be soft-deleted right after being written.
+		key := tableVersion{tableName, entry.Table, entry.Version}
+		if _, ok := seen[key]; !ok {
+			seenOrder = append(seenOrder, key)
+		}
+		seen[key] = append(seen[key], entry.Values[spec.Key])
 		pendingEntries = append(pendingEntries, entry)
 
 		if len(pendingEntries) >= flushThreshold {
-			if err := s.entryStore.SaveBatch(ctx, tx, pendingEntries, pendingTable); err != nil {
-				return fmt.Errorf("error saving batch: %w", err)
+			if err := saveBatch(); err != nil {
+				return err
 			}
-			processedRows += len(pendingEntries)
-			pendingEntries = pendingEntries[:0]
 		}
 	}
 
+	if err := parser.Err(); err != nil {
+		return err
+	}
+	countRejected()
+	warnVersions()
+
+	run.RowErrors = parser.Errors()
+	if opts.Strict && len(run.RowErrors) > 0 {
+		return fmt.Errorf("%d rows do not fit the block format, first at %s, rolling back", len(run.RowErrors), run.RowErrors[0])
+	}
+
 	if len(pendingEntries) > 0 {
-		if err := s.entryStore.SaveBatch(ctx, tx, pendingEntries, pendingTable); err != nil {
-			return fmt.Errorf("error saving batch: %w", err)
+		if err := saveBatch(); err != nil {
+			return err
 		}
-		processedRows += len(pendingEntries)
+	}
+	if err := saveQuarantine(); err != nil {
+		return err
+	}
+
+	for _, key := range seenOrder {
+		if r := incomplete[key.code]; r.rows > 0 {
+			run.Warnings = append(run.Warnings, types.RowError{
+				Location: file.At(r.headerLine, block.ColumnTable).String(),
+				Message: fmt.Sprintf("%s %s was not reconciled: %d of its rows were rejected, so no row missing from the file is deleted",
+					key.code, key.version, r.rows),
+			})
+			continue
+		}
+		keep := append(seen[key], protectedCodes(types.SpecFor(key.table), normalizer, opts.Protect[key.code])...)
+		deleted, err := s.entryStore.DeleteMissing(ctx, tx, key.table, key.code, key.version, keep)
+		if err != nil {
+			return err
+		}
+		run.Stats(key.code, key.table).Deleted += len(deleted)
+		run.Deleted = append(run.Deleted, deleted...)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -98,30 +429,31 @@ func (s *ReaderService) Read(filePath string, sheetName string) error {
 	return nil
 }
 
-func (s *ReaderService) ReadProcessSteps(filePath string, sheetName string) error {
-	ctx := context.Background()
+func (s *ReaderService) ReadProcessSteps(ctx context.Context, file source.File, versions types.Versions) (*types.ImportRun, error) {
 
-	file, err := excelize.OpenFile(filePath)
+	run, err := startRun(ctx, s.runStore, types.RunKindProcessSteps, file.Path, file.Sheet, nil)
 	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	rows, err := file.GetRows(sheetName)
-	if err != nil {
-		return fmt.Errorf("error getting rows: %w", err)
-	}
+	err = s.readProcessSteps(store.WithRun(ctx, run.ID), run, file, versions)
+	return run, finishRun(ctx, s.runStore, run, err)
+}
 
-	if len(rows) == 0 {
-		return nil
+func (s *ReaderService) readProcessSteps(ctx context.Context, run *types.ImportRun, file source.File, versions types.Versions) error {
+	rows, err := file.Open()
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
 	var lastProcesso string
 
 	processesMap := make(map[string][]string)
 
-	for i, row := range rows {
-		if i == 0 {
+	for header := true; rows.Next(); header = false {
+		row := rows.Row()
+		if header {
 			continue
 		}
 		var processo, passo string
@@ -148,6 +480,9 @@ func (s *ReaderService) ReadProcessSteps(filePath string, sheetName string) erro
 
 		processesMap[processo] = append(processesMap[processo], passo)
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
 	tx, err := s.entryStore.BeginTx(ctx)
 	if err != nil {
@@ -155,26 +490,53 @@ func (s *ReaderService) ReadProcessSteps(filePath string, sheetName string) erro
 	}
 	defer tx.Rollback()
 
+	// Processes take their descriptions from T00010. Without an imported
+	// version of it they are saved without one.
+	processes := catalog.New(tx, catalog.WithVersions(versions))
+	processesCode := types.TableCodeOf(types.TABLE_PROCESSES)
+	descriptions := true
+	if err := processes.Warm(ctx, processesCode); errors.Is(err, catalog.ErrUnknownTable) {
+		descriptions = false
+	} else if err != nil {
+		return fmt.Errorf("error loading process descriptions: %w", err)
+	}
+	stepsVersion, err := s.entryStore.ResolveVersion(ctx, tx, types.TABLE_STEPS, versions)
+	if err != nil {
+		return err
+	}
+
+	var processStats, linkStats types.TableStats
+
 	for processCode, stepCodes := range processesMap {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("process import stopped after %d of %d processes, rolling back: %w",
+				processStats.Inserted+processStats.Updated+processStats.Unchanged, len(processesMap), err)
+		}
+
 		var description string
-		err := tx.QueryRowContext(ctx, `
-			SELECT COALESCE(cv.description, '')
-			FROM catalog_values cv
-			JOIN catalogs c ON cv.catalog_id = c.id
-			WHERE c.slug = 'processes' AND cv.code = $1
-			LIMIT 1
-		`, processCode).Scan(&description)
-		if err != nil && err != sql.ErrNoRows {
-			return fmt.Errorf("error fetching process description %s: %w", processCode, err)
+		if descriptions {
+			process, err := processes.Lookup(ctx, processesCode, processCode)
+			if err != nil && !errors.Is(err, catalog.ErrUnknownCode) {
+				return fmt.Errorf("error fetching process description %s: %w", processCode, err)
+			}
+			description = process.Description
 		}
 
 		var processID string
+		var inserted bool
 		err = tx.QueryRowContext(ctx, `
 			INSERT INTO processes (code, description)
 			VALUES ($1, $2)
 			ON CONFLICT (code) DO UPDATE SET description = EXCLUDED.description, updated_at = NOW()
-			RETURNING id
-		`, processCode, description).Scan(&processID)
+			WHERE processes.description IS DISTINCT FROM EXCLUDED.description
+			RETURNING id, (xmax = 0)
+		`, processCode, description).Scan(&processID, &inserted)
+		if err == sql.ErrNoRows {
+			processStats.Unchanged++
+			err = tx.QueryRowContext(ctx, `SELECT id FROM processes WHERE code = $1`, processCode).Scan(&processID)
+		} else if err == nil {
+			countWrite(&processStats, inserted)
+		}
 		if err != nil {
 			return fmt.Errorf("error saving process %s: %w", processCode, err)
 		}
@@ -182,23 +544,34 @@ func (s *ReaderService) ReadProcessSteps(filePath string, sheetName string) erro
 		for stepOrder, stepCode := range stepCodes {
 			var stepID string
 			err := tx.QueryRowContext(ctx, `
-				SELECT id FROM steps WHERE code = $1 AND deleted_at IS NULL LIMIT 1
-			`, stepCode).Scan(&stepID)
+				SELECT id FROM steps WHERE code = $1 AND table_version_id = $2 AND deleted_at IS NULL
+			`, stepCode, stepsVersion).Scan(&stepID)
 			if err != nil {
+				linkStats.Skipped++
 				continue
 			}
 
-			_, err = tx.ExecContext(ctx, `
-				INSERT INTO process_steps (process_id, step_id, step_order)
-				VALUES ($1, $2, $3)
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO process_steps (process_id, step_id, step_order, table_version_id)
+				VALUES ($1, $2, $3, $4)
 				ON CONFLICT (process_id, step_id) DO UPDATE SET step_order = $3, updated_at = NOW()
-			`, processID, stepID, stepOrder+1)
+				WHERE process_steps.step_order IS DISTINCT FROM EXCLUDED.step_order
+				RETURNING (xmax = 0)
+			`, processID, stepID, stepOrder+1, stepsVersion).Scan(&inserted)
+			if err == sql.ErrNoRows {
+				linkStats.Unchanged++
+				continue
+			}
 			if err != nil {
 				return fmt.Errorf("error linking process %s to step %s: %w", processCode, stepCode, err)
 			}
+			countWrite(&linkStats, inserted)
 		}
 	}
 
+	run.Stats("", "processes").Add(processStats)
+	run.Stats("", "process_steps").Add(linkStats)
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
@@ -206,47 +579,49 @@ func (s *ReaderService) ReadProcessSteps(filePath string, sheetName string) erro
 	return nil
 }
 
-func parseRow(row []string, tableName string) types.Entry {
-	entry := types.Entry{}
-
-	if len(row) > 0 {
-		entry.Table = row[0]
+// protectedCodes fixes --protect codes the way the key column of the table
+// is fixed on import, so they match the codes they are compared with.
+func protectedCodes(spec types.TableSpec, normalizer *normalize.Normalizer, codes []string) []string {
+	width := 0
+	for _, c := range spec.Columns {
+		if c.Name == spec.Key {
+			width = c.Width
+		}
 	}
-	if len(row) > 1 {
-		entry.Version = row[1]
+	fixed := make([]string, len(codes))
+	for i, code := range codes {
+		code, _ = normalizer.String(code)
+		fixed[i] = padCode(code, width)
 	}
+	return fixed
+}
 
-	switch tableName {
-	case "countries", "districts", "municipalities", "parishes":
-		if len(row) > 2 {
-			entry.Name = row[2]
-		}
-		if len(row) > 3 {
-			entry.Code = row[3]
-		}
-
-	case "ine_zones":
-		if len(row) > 2 {
-			entry.ZoneCode = row[2]
-		}
-		if len(row) > 3 {
-			entry.ZoneName = row[3]
-		}
-		if len(row) > 4 {
-			entry.ZoneNameFormatted = row[4]
-		}
-		if len(row) > 5 {
-			entry.INEMunicipalityCode = row[5]
+// padCode restores the leading zeros of an all-digit code shorter than its
+// declared width, as lost when the code was typed as a number.
+func padCode(code string, width int) string {
+	if code == "" || len(code) >= width {
+		return code
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return code
 		}
+	}
+	return strings.Repeat("0", width-len(code)) + code
+}
 
-	default:
-		if len(row) > 2 {
-			entry.Code = row[2]
-		}
-		if len(row) > 3 {
-			entry.Description = row[3]
-		}
+func joinRules(rules []normalize.Rule) string {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = string(r)
 	}
+	return strings.Join(names, ",")
+}
 
-	return entry
+func countWrite(stats *types.TableStats, inserted bool) {
+	if inserted {
+		stats.Inserted++
+	} else {
+		stats.Updated++
+	}
 }
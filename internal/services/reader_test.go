@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lantoniomiranda/shitreader/internal/normalize"
+	"github.com/lantoniomiranda/shitreader/internal/source"
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type nopTx struct{}
+
+func (nopTx) ExecContext(context.Context, string, ...any) (sql.Result, error) { return nil, nil }
+func (nopTx) QueryContext(context.Context, string, ...any) (*sql.Rows, error) { return nil, nil }
+func (nopTx) QueryRowContext(context.Context, string, ...any) *sql.Row        { return nil }
+func (nopTx) Commit() error                                                   { return nil }
+func (nopTx) Rollback() error                                                 { return nil }
+
+// memoryEntryStore keeps the headers and codes that were saved and the codes
+// that reconciliation was told to keep, by table version.
+type memoryEntryStore struct {
+	headers []string
+	saved   map[string][]string
+	kept    map[string][]string
+}
+
+func newMemoryEntryStore() *memoryEntryStore {
+	return &memoryEntryStore{saved: map[string][]string{}, kept: map[string][]string{}}
+}
+
+func (s *memoryEntryStore) BeginTx(context.Context) (store.Tx, error) { return nopTx{}, nil }
+
+func (s *memoryEntryStore) SaveHeader(_ context.Context, _ store.Tx, _ string, header types.TableHeader) error {
+	s.headers = append(s.headers, header.TableCode+" "+header.Version)
+	return nil
+}
+
+func (s *memoryEntryStore) SaveBatch(_ context.Context, _ store.Tx, entries []types.Entry, tableName string) (types.TableStats, error) {
+	spec := types.SpecFor(tableName)
+	for _, e := range entries {
+		s.saved[e.Table+" "+e.Version] = append(s.saved[e.Table+" "+e.Version], e.Values[spec.Key])
+	}
+	return types.TableStats{Inserted: len(entries)}, nil
+}
+
+func (s *memoryEntryStore) SaveQuarantine(context.Context, store.Tx, string, types.QuarantinedBlock) error {
+	return nil
+}
+
+func (s *memoryEntryStore) DeleteMissing(_ context.Context, _ store.Tx, _ string, tableCode string, version string, keep []string) ([]types.DeletedRow, error) {
+	var deleted []types.DeletedRow
+	for _, code := range s.saved[tableCode+" "+version] {
+		if !slices.Contains(keep, code) {
+			deleted = append(deleted, types.DeletedRow{TableCode: tableCode, Version: version, Code: code})
+		}
+	}
+	s.kept[tableCode+" "+version] = keep
+	return deleted, nil
+}
+
+func (s *memoryEntryStore) ResolveVersion(context.Context, store.Tx, string, types.Versions) (string, error) {
+	return "", nil
+}
+
+type memoryRunStore struct{}
+
+func (memoryRunStore) StartRun(_ context.Context, run *types.ImportRun) error {
+	run.ID = "00000000-0000-0000-0000-000000000001"
+	run.Status = types.RunStatusRunning
+	return nil
+}
+
+func (memoryRunStore) FinishRun(context.Context, *types.ImportRun) error { return nil }
+func (memoryRunStore) MarkSucceeded(context.Context, []string) error     { return nil }
+func (memoryRunStore) MarkRolledBack(context.Context, []string) error    { return nil }
+func (memoryRunStore) ListRuns(context.Context, int) ([]types.ImportRun, error) {
+	return nil, nil
+}
+func (memoryRunStore) LastImportedHash(context.Context, *types.ImportRun) (string, error) {
+	return "", nil
+}
+func (memoryRunStore) LatestRefreshes(context.Context) ([]types.TableRefresh, error) {
+	return nil, nil
+}
+func (memoryRunStore) ListQuarantined(context.Context) ([]types.QuarantinedBlock, error) {
+	return nil, nil
+}
+
+func TestReadKeepsFixedCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ine-zonas.csv")
+	rows := "T10150,V01.00\n" +
+		"T10150,V01.00,1317RS,Residual,RESIDUAL,1317\n" +
+		"T10150,V01.00,9744,Tocas,TOCAS,1305\n" +
+		"T10150,V01.00,0115RS ,Residual,RESIDUAL,0115\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := newMemoryEntryStore()
+	reader := NewReaderService(entries, memoryRunStore{})
+	run, err := reader.Read(context.Background(), source.File{Path: path}, ReadOptions{Normalize: normalize.Default})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"1317RS", "009744", "0115RS"}; !slices.Equal(entries.saved["T10150 V01.00"], want) {
+		t.Errorf("saved codes %q, want %q", entries.saved["T10150 V01.00"], want)
+	}
+	if len(run.TextFixes) != 2 {
+		t.Errorf("got %d text fixes, want the padded and the trimmed code: %v", len(run.TextFixes), run.TextFixes)
+	}
+	if len(run.Deleted) > 0 {
+		t.Errorf("fixed codes were soft-deleted: %v (kept %q)", run.Deleted, entries.kept["T10150 V01.00"])
+	}
+}
+
+func TestReadStoresRowsUnderTheirOwnVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ine-zonas.csv")
+	rows := "T10150,V01.01\n" +
+		"T10150,V01.00,1317RS,Residual,RESIDUAL,1317\n" +
+		"T10150,,0115RS,Residual,RESIDUAL,0115\n" +
+		"T10150,V01.02,009744,Tocas,TOCAS,1305\n" +
+		"T10150,V01.00,036747,Lapas,LAPAS,1305\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := newMemoryEntryStore()
+	reader := NewReaderService(entries, memoryRunStore{})
+	run, err := reader.Read(context.Background(), source.File{Path: path}, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"T10150 V01.00": {"1317RS", "036747"},
+		"T10150 V01.01": {"0115RS"},
+		"T10150 V01.02": {"009744"},
+	}
+	for version, codes := range want {
+		if !slices.Equal(entries.saved[version], codes) {
+			t.Errorf("saved under %s: %q, want %q", version, entries.saved[version], codes)
+		}
+	}
+	if want := []string{"T10150 V01.00", "T10150 V01.01", "T10150 V01.02"}; !slices.Equal(entries.headers, want) {
+		t.Errorf("headers saved for %q, want %q", entries.headers, want)
+	}
+	if len(run.RowErrors) > 0 {
+		t.Errorf("rows were rejected: %v", run.RowErrors)
+	}
+	if len(run.Warnings) != 1 {
+		t.Errorf("got %d warnings, want one for the block: %v", len(run.Warnings), run.Warnings)
+	}
+}
+
+func TestReadDoesNotReconcileTablesWithRejectedRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ine-zonas.csv")
+	rows := "T10150,V01.00\n" +
+		"T10150,V01.00,1317RS,Residual,RESIDUAL,1317\n" +
+		"T10151,V01.01,036747,Lapas,LAPAS,1305\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := newMemoryEntryStore()
+	entries.saved["T10150 V01.00"] = []string{"036747"}
+	reader := NewReaderService(entries, memoryRunStore{})
+	run, err := reader.Read(context.Background(), source.File{Path: path}, ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(run.RowErrors) != 1 {
+		t.Errorf("got %d row errors, want the drag-filled row: %v", len(run.RowErrors), run.RowErrors)
+	}
+	if len(run.Deleted) > 0 {
+		t.Errorf("rows of a table with rejected rows were soft-deleted: %v", run.Deleted)
+	}
+	if len(run.Warnings) != 1 {
+		t.Errorf("got %d warnings, want one for the table that was not reconciled: %v", len(run.Warnings), run.Warnings)
+	}
+}
+
+func TestReadProtectsFixedCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ine-zonas.csv")
+	rows := "T10150,V01.00\n" +
+		"T10150,V01.00,1317RS,Residual,RESIDUAL,1317\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := newMemoryEntryStore()
+	entries.saved["T10150 V01.00"] = []string{"000001", "ADDED"}
+	reader := NewReaderService(entries, memoryRunStore{})
+	run, err := reader.Read(context.Background(), source.File{Path: path}, ReadOptions{
+		Normalize: normalize.Default,
+		Protect:   map[string][]string{"T10150": {"1", " ADDED\u200b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(run.Deleted) > 0 {
+		t.Errorf("protected codes were soft-deleted: %v (kept %q)", run.Deleted, entries.kept["T10150 V01.00"])
+	}
+}
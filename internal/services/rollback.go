@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type RollbackService struct {
+	rollbackStore store.RollbackStore
+	runStore      store.RunStore
+}
+
+func NewRollbackService(rollbackStore store.RollbackStore, runStore store.RunStore) *RollbackService {
+	return &RollbackService{
+		rollbackStore: rollbackStore,
+		runStore:      runStore,
+	}
+}
+
+// Rollback undoes the changes an import run made, as recorded in the change
+// log. The rollback is a run of its own, so its changes are logged too and
+// can be rolled back in turn.
+func (s *RollbackService) Rollback(ctx context.Context, runID string, force bool) (*types.ImportRun, error) {
+	target, err := s.rollbackStore.ResolveRun(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("error finding import run: %w", err)
+	}
+	if target.Status != types.RunStatusSucceeded {
+		return nil, fmt.Errorf("import run %s is %s, only succeeded runs can be rolled back", target.ID, target.Status)
+	}
+
+	run, err := startRun(ctx, s.runStore, types.RunKindRollback, "", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error recording rollback run: %w", err)
+	}
+
+	stats, err := s.rollbackStore.Rollback(ctx, target.ID, run.ID, force)
+	if err != nil {
+		err = fmt.Errorf("error rolling back import run %s: %w", target.ID, err)
+	}
+	run.Tables = stats
+	return run, finishRun(ctx, s.runStore, run, err)
+}
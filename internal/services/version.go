@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/store"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type VersionService struct {
+	versionStore store.VersionStore
+}
+
+func NewVersionService(versionStore store.VersionStore) *VersionService {
+	return &VersionService{
+		versionStore: versionStore,
+	}
+}
+
+// SetValidity sets the dates a table version applies from and, optionally,
+// until (exclusive). Without an end date the version applies until a version
+// with a later start takes over.
+func (s *VersionService) SetValidity(ctx context.Context, tableCode string, version string, from time.Time, to *time.Time) error {
+	if to != nil && !to.After(from) {
+		return fmt.Errorf("%s %s: valid to %s is not after valid from %s",
+			tableCode, version, to.Format(types.DateLayout), from.Format(types.DateLayout))
+	}
+	return s.versionStore.SetValidity(ctx, tableCode, version, from, to)
+}
+
+// Promote makes version the active version of a table code, the one lookups,
+// associations and exports read by default. A version older than the current
+// one (the active version, or the highest imported one when none was
+// promoted) is only promoted with force, so a table is not taken back by
+// mistake. Versions are compared by their numbers. It returns the version that
+// was active before, if any.
+func (s *VersionService) Promote(ctx context.Context, tableCode string, version string, force bool) (string, error) {
+	active, err := s.versionStore.ActiveVersion(ctx, tableCode)
+	if err != nil {
+		return "", err
+	}
+	if active == version {
+		return active, fmt.Errorf("%s %s is already the active version", tableCode, version)
+	}
+	current, err := s.versionStore.CurrentVersion(ctx, tableCode)
+	if err != nil {
+		return active, err
+	}
+	if current != "" && types.CompareVersions(version, current) < 0 && !force {
+		return active, fmt.Errorf("%s %s is older than the current version %s (use --force)", tableCode, version, current)
+	}
+
+	if err := s.versionStore.Promote(ctx, tableCode, version); err != nil {
+		return active, err
+	}
+	return active, nil
+}
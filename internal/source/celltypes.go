@@ -0,0 +1,186 @@
+package source
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// cellTypes streams the sheet XML next to excelize's row iterator to tell
+// which cells hold numbers. excelize only hands out the formatted strings, so
+// a code typed as a number is otherwise indistinguishable from text.
+type cellTypes struct {
+	archive *zip.ReadCloser
+	sheet   io.ReadCloser
+	decoder *xml.Decoder
+	row     int
+	numeric map[int]bool
+	done    bool
+}
+
+func openCellTypes(workbook string, sheet string) (*cellTypes, error) {
+	archive, err := zip.OpenReader(workbook)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	target, err := sheetPath(&archive.Reader, sheet)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+
+	for _, f := range archive.File {
+		if f.Name != target {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			archive.Close()
+			return nil, fmt.Errorf("error opening sheet %s: %w", sheet, err)
+		}
+		return &cellTypes{archive: archive, sheet: r, decoder: xml.NewDecoder(r)}, nil
+	}
+
+	archive.Close()
+	return nil, fmt.Errorf("sheet %s not found in %s", sheet, workbook)
+}
+
+// sheetPath resolves a sheet name to its part in the archive through
+// xl/workbook.xml and its relationships.
+func sheetPath(archive *zip.Reader, sheet string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readXML(archive, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readXML(archive, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+
+	for _, s := range workbook.Sheets {
+		if s.Name != sheet {
+			continue
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID != s.ID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "", fmt.Errorf("sheet %s does not exist", sheet)
+}
+
+func readXML(archive *zip.Reader, name string, v any) error {
+	f, err := archive.Open(name)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// at returns the numeric columns of the given row. Rows must be asked for in
+// increasing order.
+func (t *cellTypes) at(row int) (map[int]bool, error) {
+	for !t.done && t.row < row {
+		if err := t.next(); err != nil {
+			return nil, err
+		}
+	}
+	if t.row != row {
+		return nil, nil
+	}
+	return t.numeric, nil
+}
+
+func (t *cellTypes) next() error {
+	t.numeric = nil
+	column := 0
+	// number is set inside a cell without a text type; the cell is only
+	// numeric when it also has a value, styled empty cells have none.
+	number := false
+	for {
+		token, err := t.decoder.Token()
+		if err == io.EOF {
+			t.done = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading cell types: %w", err)
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "row":
+				t.row++
+				if r := attr(el, "r"); r != "" {
+					fmt.Sscan(r, &t.row)
+				}
+				column = 0
+			case "c":
+				column++
+				if ref := attr(el, "r"); ref != "" {
+					if col, _, err := excelize.CellNameToCoordinates(ref); err == nil {
+						column = col
+					}
+				}
+				kind := attr(el, "t")
+				number = kind == "" || kind == "n"
+			case "v":
+				if number {
+					if t.numeric == nil {
+						t.numeric = make(map[int]bool)
+					}
+					t.numeric[column] = true
+				}
+			}
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "c":
+				number = false
+			case "row":
+				return nil
+			}
+		}
+	}
+}
+
+func (t *cellTypes) Close() error {
+	t.sheet.Close()
+	return t.archive.Close()
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
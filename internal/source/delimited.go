@@ -0,0 +1,76 @@
+package source
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type delimitedReader struct {
+	file   *os.File
+	reader *csv.Reader
+	row    []string
+	line   int
+	err    error
+}
+
+func openDelimited(path string, comma rune) (Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	reader := csv.NewReader(file)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return &delimitedReader{file: file, reader: reader}, nil
+}
+
+func (r *delimitedReader) Next() bool {
+	for r.err == nil {
+		record, err := r.reader.Read()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			r.err = fmt.Errorf("error reading %s: %w", r.file.Name(), err)
+			return false
+		}
+
+		line, _ := r.reader.FieldPos(0)
+		if line == 1 && len(record) > 0 {
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+		}
+		if row := trimRow(record); len(row) > 0 {
+			r.row = row
+			r.line = line
+			return true
+		}
+	}
+	return false
+}
+
+func (r *delimitedReader) Row() []string {
+	return r.row
+}
+
+func (r *delimitedReader) Line() int {
+	return r.line
+}
+
+// Numeric is always false: delimited files only hold text.
+func (r *delimitedReader) Numeric(column int) bool {
+	return false
+}
+
+func (r *delimitedReader) Err() error {
+	return r.err
+}
+
+func (r *delimitedReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,123 @@
+package source
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	FormatXLSX Format = "xlsx"
+	FormatCSV  Format = "csv"
+	FormatTSV  Format = "tsv"
+)
+
+// Reader walks the rows of a source one at a time. Empty rows are skipped and
+// rows have their trailing empty cells removed, so every format looks the same
+// to the importers.
+type Reader interface {
+	Next() bool
+	Row() []string
+	// Line is the 1-based row number of the current row in the source.
+	Line() int
+	// Numeric reports whether the cell in the 1-based column of the current
+	// row was stored as a number rather than text.
+	Numeric(column int) bool
+	Err() error
+	Close() error
+}
+
+// File identifies a row source. Sheet is only used by workbooks and an empty
+// Format is detected from the file extension.
+type File struct {
+	Path   string
+	Sheet  string
+	Format Format
+}
+
+func (f File) Open() (Reader, error) {
+	format := f.Format
+	if format == "" {
+		detected, err := DetectFormat(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+
+	switch format {
+	case FormatXLSX:
+		return openXLSX(f.Path, f.Sheet)
+	case FormatCSV:
+		return openDelimited(f.Path, ',')
+	case FormatTSV:
+		return openDelimited(f.Path, '\t')
+	default:
+		return nil, fmt.Errorf("unknown source format %q", format)
+	}
+}
+
+// Location is a cell in a source, shown as "file.xlsx!Sheet!C245" or, for
+// delimited files, "file.csv!C245". Row and Column are 1-based.
+type Location struct {
+	File   string
+	Sheet  string
+	Row    int
+	Column int
+}
+
+func (f File) At(row int, column int) Location {
+	format := f.Format
+	if format == "" {
+		format, _ = DetectFormat(f.Path)
+	}
+	sheet := f.Sheet
+	if format != FormatXLSX {
+		sheet = ""
+	}
+	return Location{File: filepath.Base(f.Path), Sheet: sheet, Row: row, Column: column}
+}
+
+func (l Location) String() string {
+	cell, err := excelize.CoordinatesToCellName(l.Column, l.Row)
+	if err != nil {
+		cell = fmt.Sprintf("R%dC%d", l.Row, l.Column)
+	}
+	if l.Sheet == "" {
+		return l.File + "!" + cell
+	}
+	return l.File + "!" + l.Sheet + "!" + cell
+}
+
+func DetectFormat(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx", ".xlsm":
+		return FormatXLSX, nil
+	case ".csv":
+		return FormatCSV, nil
+	case ".tsv", ".tab":
+		return FormatTSV, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %s from its extension", path)
+	}
+}
+
+func (f Format) Valid() bool {
+	switch f {
+	case FormatXLSX, FormatCSV, FormatTSV:
+		return true
+	}
+	return false
+}
+
+func trimRow(row []string) []string {
+	end := len(row)
+	for end > 0 && row[end-1] == "" {
+		end--
+	}
+	return row[:end]
+}
@@ -0,0 +1,92 @@
+package source
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxReader streams the sheet with excelize's row iterator, so only the
+// current row is held in memory.
+type xlsxReader struct {
+	file    *excelize.File
+	rows    *excelize.Rows
+	types   *cellTypes
+	row     []string
+	numeric map[int]bool
+	line    int
+	err     error
+}
+
+func openXLSX(path string, sheet string) (Reader, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	rows, err := file.Rows(sheet)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error getting rows: %w", err)
+	}
+
+	types, err := openCellTypes(path, sheet)
+	if err != nil {
+		rows.Close()
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxReader{file: file, rows: rows, types: types}, nil
+}
+
+// Next skips empty rows; Line still reports the row number in the sheet.
+func (r *xlsxReader) Next() bool {
+	for r.err == nil && r.rows.Next() {
+		r.line++
+		row, err := r.rows.Columns()
+		if err != nil {
+			r.err = fmt.Errorf("error reading row %d: %w", r.line, err)
+			return false
+		}
+		if row = trimRow(row); len(row) > 0 {
+			if r.numeric, err = r.types.at(r.line); err != nil {
+				r.err = err
+				return false
+			}
+			r.row = row
+			return true
+		}
+	}
+	if r.err == nil {
+		if err := r.rows.Error(); err != nil {
+			r.err = fmt.Errorf("error reading rows: %w", err)
+		}
+	}
+	return false
+}
+
+func (r *xlsxReader) Row() []string {
+	return r.row
+}
+
+func (r *xlsxReader) Line() int {
+	return r.line
+}
+
+func (r *xlsxReader) Numeric(column int) bool {
+	return r.numeric[column]
+}
+
+func (r *xlsxReader) Err() error {
+	return r.err
+}
+
+func (r *xlsxReader) Close() error {
+	rowsErr := r.rows.Close()
+	r.types.Close()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return rowsErr
+}
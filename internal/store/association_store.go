@@ -4,342 +4,299 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
 
-	"github.com/xuri/excelize/v2"
+	"github.com/lantoniomiranda/shitreader/internal/types"
 )
 
 type PostgresAssociationStore struct {
-	db *sql.DB
+	txManager *TxManager
 }
 
-func NewPostgresAssociationStore(db *sql.DB) *PostgresAssociationStore {
+func NewPostgresAssociationStore(txManager *TxManager) *PostgresAssociationStore {
 	return &PostgresAssociationStore{
-		db: db,
+		txManager: txManager,
 	}
 }
 
+// AssociationStore links rows of the current version of each table, or of
+// the version pinned in versions, so links never mix versions.
 type AssociationStore interface {
-	AssociateRecordsFields(ctx context.Context) error
-	AssociateRecordsRecordTypes(ctx context.Context, filePath string, sheetName string) error
-	AssociateStepsHeaderTypesAndRecords(ctx context.Context, filePath string, sheetName string) error
+	AssociateRecordsFields(ctx context.Context, versions types.Versions) ([]types.TableStats, error)
+	AssociateRecordsRecordTypes(ctx context.Context, links []types.RecordTypeLink, versions types.Versions) ([]types.TableStats, error)
+	AssociateStepsHeaderTypesAndRecords(ctx context.Context, steps []types.StepLinks, versions types.Versions) ([]types.TableStats, error)
 }
 
-func (s *PostgresAssociationStore) AssociateRecordsFields(ctx context.Context) error {
-	tx, err := s.db.BeginTx(ctx, nil)
+func (s *PostgresAssociationStore) AssociateRecordsFields(ctx context.Context, versions types.Versions) ([]types.TableStats, error) {
+	tx, err := s.txManager.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	fieldsVersion, err := resolveVersion(ctx, tx, types.TABLE_FIELDS, versions)
+	if err != nil {
+		return nil, err
+	}
+	recordsVersion, err := resolveVersion(ctx, tx, types.TABLE_RECORDS, versions)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE fields f
-		SET record_id = r.id
+		SET record_id = r.id, updated_at = NOW()
 		FROM records r
 		WHERE LEFT(f.code, 5) = LEFT(r.code, 5)
+		  AND f.table_version_id = $1
+		  AND r.table_version_id = $2
 		  AND f.deleted_at IS NULL
 		  AND r.deleted_at IS NULL
-		  AND f.record_id IS NULL
+		  AND f.record_id IS DISTINCT FROM r.id
 	`
 
-	_, err = tx.ExecContext(ctx, query)
+	result, err := tx.ExecContext(ctx, query, fieldsVersion, recordsVersion)
 	if err != nil {
-		return fmt.Errorf("failed to associate fields with records: %w", err)
+		return nil, fmt.Errorf("failed to associate fields with records: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit field-record association: %w", err)
+		return nil, fmt.Errorf("failed to commit field-record association: %w", err)
 	}
 
-	return nil
+	return []types.TableStats{{Table: "fields", Updated: int(rowsAffected)}}, nil
 }
 
-func (s *PostgresAssociationStore) AssociateRecordsRecordTypes(ctx context.Context, filePath string, sheetName string) error {
-	file, err := excelize.OpenFile(filePath)
+func (s *PostgresAssociationStore) AssociateRecordsRecordTypes(ctx context.Context, links []types.RecordTypeLink, versions types.Versions) ([]types.TableStats, error) {
+	tx, err := s.txManager.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer file.Close()
+	defer tx.Rollback()
 
-	rows, err := file.GetRows(sheetName)
+	recordTypesVersion, err := resolveVersion(ctx, tx, types.TABLE_RECORD_TYPES, versions)
 	if err != nil {
-		return fmt.Errorf("error getting rows: %w", err)
+		return nil, err
 	}
-
-	recordTypesMap := make(map[string]string)
-	recordTypesQuery := `
-		SELECT cv.id, cv.code 
-		FROM catalog_values cv
-		JOIN catalogs c ON cv.catalog_id = c.id
-		WHERE c.slug = 'record_types' AND cv.deleted_at IS NULL
-	`
-
-	recordTypeRows, err := s.db.QueryContext(ctx, recordTypesQuery)
+	recordsVersion, err := resolveVersion(ctx, tx, types.TABLE_RECORDS, versions)
 	if err != nil {
-		return fmt.Errorf("failed to load record types: %w", err)
-	}
-	defer recordTypeRows.Close()
-
-	for recordTypeRows.Next() {
-		var id, code string
-		if err := recordTypeRows.Scan(&id, &code); err != nil {
-			return fmt.Errorf("failed to scan record type: %w", err)
-		}
-		recordTypesMap[code] = id
+		return nil, err
 	}
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	recordTypesMap, err := loadCodeMap(ctx, tx, `
+		SELECT cv.id, cv.code
+		FROM catalog_values cv
+		JOIN catalogs c ON cv.catalog_id = c.id
+		WHERE c.slug = 'record_types' AND cv.table_version_id = $1 AND cv.deleted_at IS NULL
+	`, "record types", recordTypesVersion)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
 
-	associatedCount := 0
-	skippedCount := 0
+	var stats types.TableStats
 
-	for i, row := range rows {
-		if i == 0 {
-			continue
-		}
-		if len(row) < 3 {
-			continue
+	for i, link := range links {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("record type association stopped at link %d of %d after %d records, rolling back: %w", i+1, len(links), stats.Updated, err)
 		}
 
-		recordCode := row[1]
-		recordTypeCode := row[2]
-
-		if recordCode == "" || recordTypeCode == "" {
-			continue
-		}
+		recordCode := link.RecordCode
+		recordTypeCode := link.RecordTypeCode
 
 		recordTypeId, exists := recordTypesMap[recordTypeCode]
 		if !exists {
-			skippedCount++
+			stats.Skipped++
 			continue
 		}
 
+		// Only records whose type changes are written, so unchanged ones
+		// keep their updated_at and are counted as unchanged.
 		updateQuery := `
-			UPDATE records 
-			SET record_type_id = $1 
-			WHERE code = $2 
-			AND deleted_at IS NULL
+			WITH target AS (
+				SELECT id, record_type_id FROM records
+				WHERE code = $2 AND table_version_id = $3 AND deleted_at IS NULL
+			), updated AS (
+				UPDATE records r
+				SET record_type_id = $1, updated_at = NOW()
+				FROM target t
+				WHERE r.id = t.id AND t.record_type_id IS DISTINCT FROM $1
+				RETURNING r.id
+			)
+			SELECT (SELECT COUNT(*) FROM target), (SELECT COUNT(*) FROM updated)
 		`
 
-		result, err := tx.ExecContext(ctx, updateQuery, recordTypeId, recordCode)
-		if err != nil {
-			return fmt.Errorf("failed to update record %s with record_type_id %s: %w", recordCode, recordTypeId, err)
-		}
-
-		rowsAffected, err := result.RowsAffected()
+		var found, updated int
+		err := tx.QueryRowContext(ctx, updateQuery, recordTypeId, recordCode, recordsVersion).Scan(&found, &updated)
 		if err != nil {
-			return fmt.Errorf("failed to get rows affected: %w", err)
+			return nil, fmt.Errorf("failed to update record %s with record_type_id %s: %w", recordCode, recordTypeId, err)
 		}
 
-		if rowsAffected == 0 {
-			skippedCount++
-			continue
+		switch {
+		case found == 0:
+			stats.Skipped++
+		case updated == 0:
+			stats.Unchanged++
+		default:
+			stats.Updated++
 		}
-
-		associatedCount++
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit record-type association: %w", err)
+		return nil, fmt.Errorf("failed to commit record-type association: %w", err)
 	}
 
-	return nil
+	stats.Table = "records"
+	return []types.TableStats{stats}, nil
 }
 
-func (s *PostgresAssociationStore) AssociateStepsHeaderTypesAndRecords(ctx context.Context, filePath string, sheetName string) error {
-	file, err := excelize.OpenFile(filePath)
+func (s *PostgresAssociationStore) AssociateStepsHeaderTypesAndRecords(ctx context.Context, steps []types.StepLinks, versions types.Versions) ([]types.TableStats, error) {
+	tx, err := s.txManager.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer file.Close()
+	defer tx.Rollback()
 
-	rows, err := file.GetRows(sheetName)
+	headerTypesVersion, err := resolveVersion(ctx, tx, types.TABLE_HEADER_TYPES, versions)
 	if err != nil {
-		return fmt.Errorf("error getting rows: %w", err)
+		return nil, err
 	}
-
-	headerTypesMap := make(map[string]string)
-	headerTypesQuery := `
-		SELECT cv.id, cv.code 
-		FROM catalog_values cv
-		JOIN catalogs c ON cv.catalog_id = c.id
-		WHERE c.slug = 'header_types' AND cv.deleted_at IS NULL
-	`
-
-	headerTypeRows, err := s.db.QueryContext(ctx, headerTypesQuery)
+	recordsVersion, err := resolveVersion(ctx, tx, types.TABLE_RECORDS, versions)
 	if err != nil {
-		return fmt.Errorf("failed to load header types: %w", err)
-	}
-	defer headerTypeRows.Close()
-
-	for headerTypeRows.Next() {
-		var id, code string
-		if err := headerTypeRows.Scan(&id, &code); err != nil {
-			return fmt.Errorf("failed to scan header type: %w", err)
-		}
-		headerTypesMap[code] = id
+		return nil, err
 	}
-
-	recordsMap := make(map[string]string)
-	recordsQuery := `SELECT id, code FROM records WHERE deleted_at IS NULL`
-
-	recordRows, err := s.db.QueryContext(ctx, recordsQuery)
+	stepsVersion, err := resolveVersion(ctx, tx, types.TABLE_STEPS, versions)
 	if err != nil {
-		return fmt.Errorf("failed to load records: %w", err)
-	}
-	defer recordRows.Close()
-
-	for recordRows.Next() {
-		var id, code string
-		if err := recordRows.Scan(&id, &code); err != nil {
-			return fmt.Errorf("failed to scan record: %w", err)
-		}
-		recordsMap[code] = id
+		return nil, err
 	}
 
-	stepsMap := make(map[string]string)
-	stepsQuery := `SELECT id, code FROM steps WHERE deleted_at IS NULL`
-
-	stepRows, err := s.db.QueryContext(ctx, stepsQuery)
+	headerTypesMap, err := loadCodeMap(ctx, tx, `
+		SELECT cv.id, cv.code
+		FROM catalog_values cv
+		JOIN catalogs c ON cv.catalog_id = c.id
+		WHERE c.slug = 'header_types' AND cv.table_version_id = $1 AND cv.deleted_at IS NULL
+	`, "header types", headerTypesVersion)
 	if err != nil {
-		return fmt.Errorf("failed to load steps: %w", err)
+		return nil, err
 	}
-	defer stepRows.Close()
 
-	for stepRows.Next() {
-		var id, code string
-		if err := stepRows.Scan(&id, &code); err != nil {
-			return fmt.Errorf("failed to scan step: %w", err)
-		}
-		stepsMap[code] = id
+	recordsMap, err := loadCodeMap(ctx, tx, `SELECT id, code FROM records WHERE table_version_id = $1 AND deleted_at IS NULL`, "records", recordsVersion)
+	if err != nil {
+		return nil, err
 	}
 
-	type stepData struct {
-		headerTypeCodes []string
-		recordCodes     []string
+	stepsMap, err := loadCodeMap(ctx, tx, `SELECT id, code FROM steps WHERE table_version_id = $1 AND deleted_at IS NULL`, "steps", stepsVersion)
+	if err != nil {
+		return nil, err
 	}
-	stepRecordsData := make(map[string]*stepData)
-
-	var lastStepCode string
-	var lastHeaderTypeCode string
 
-	for i, row := range rows {
-		if i == 0 {
-			continue
-		}
-		if len(row) < 3 {
-			continue
-		}
-
-		stepCode := row[0]
-		headerTypeCode := row[1]
-		recordCode := row[2]
-
-		if stepCode != "" {
-			lastStepCode = stepCode
-		} else {
-			stepCode = lastStepCode
-		}
-
-		if headerTypeCode != "" {
-			lastHeaderTypeCode = headerTypeCode
-		} else {
-			headerTypeCode = lastHeaderTypeCode
-		}
-
-		if stepCode == "" || headerTypeCode == "" || recordCode == "" {
-			continue
-		}
+	processedSteps := 0
+	headerTypeStats := types.TableStats{Table: "step_header_types"}
+	recordStats := types.TableStats{Table: "step_records"}
 
-		sd, exists := stepRecordsData[stepCode]
-		if !exists {
-			sd = &stepData{}
-			stepRecordsData[stepCode] = sd
+	for _, data := range steps {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("step association stopped after %d of %d steps, rolling back: %w", processedSteps, len(steps), err)
 		}
 
-		if len(sd.headerTypeCodes) == 0 {
-			headerTypes := strings.Split(headerTypeCode, ",")
-			for _, ht := range headerTypes {
-				trimmedHT := strings.TrimSpace(ht)
-				if trimmedHT != "" {
-					sd.headerTypeCodes = append(sd.headerTypeCodes, trimmedHT)
-				}
-			}
-		}
+		stepCode := data.StepCode
 
-		sd.recordCodes = append(sd.recordCodes, recordCode)
-	}
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	processedSteps := 0
-	createdStepHeaderTypes := 0
-	createdStepRecords := 0
-	skippedRows := 0
-
-	for stepCode, data := range stepRecordsData {
 		stepId, stepExists := stepsMap[stepCode]
 		if !stepExists {
-			skippedRows++
+			headerTypeStats.Skipped += len(data.HeaderTypeCodes)
+			recordStats.Skipped += len(data.RecordCodes)
 			continue
 		}
 
 		processedSteps++
 
-		for _, headerTypeCode := range data.headerTypeCodes {
+		for _, headerTypeCode := range data.HeaderTypeCodes {
 			headerTypeId, headerTypeExists := headerTypesMap[headerTypeCode]
 			if !headerTypeExists {
-				skippedRows++
+				headerTypeStats.Skipped++
 				continue
 			}
 
 			insertHeaderTypeQuery := `
-				INSERT INTO step_header_types (step_id, header_type_id)
-				VALUES ($1, $2)
+				INSERT INTO step_header_types (step_id, header_type_id, table_version_id)
+				VALUES ($1, $2, $3)
 				ON CONFLICT (step_id, header_type_id) DO NOTHING
 			`
 
-			_, err := tx.ExecContext(ctx, insertHeaderTypeQuery, stepId, headerTypeId)
+			result, err := tx.ExecContext(ctx, insertHeaderTypeQuery, stepId, headerTypeId, stepsVersion)
 			if err != nil {
-				return fmt.Errorf("failed to create step_header_type for step %s and header type %s: %w", stepCode, headerTypeCode, err)
+				return nil, fmt.Errorf("failed to create step_header_type for step %s and header type %s: %w", stepCode, headerTypeCode, err)
 			}
 
-			createdStepHeaderTypes++
+			if err := countLink(&headerTypeStats, result); err != nil {
+				return nil, err
+			}
 		}
 
-		for _, recordCode := range data.recordCodes {
+		for _, recordCode := range data.RecordCodes {
 			recordId, recordExists := recordsMap[recordCode]
 			if !recordExists {
-				skippedRows++
+				recordStats.Skipped++
 				continue
 			}
 
 			insertQuery := `
-				INSERT INTO step_records (step_id, record_id)
-				VALUES ($1, $2)
+				INSERT INTO step_records (step_id, record_id, table_version_id)
+				VALUES ($1, $2, $3)
 				ON CONFLICT (step_id, record_id) DO NOTHING
 			`
 
-			_, err := tx.ExecContext(ctx, insertQuery, stepId, recordId)
+			result, err := tx.ExecContext(ctx, insertQuery, stepId, recordId, stepsVersion)
 			if err != nil {
-				return fmt.Errorf("failed to create step_record for step %s and record %s: %w", stepCode, recordCode, err)
+				return nil, fmt.Errorf("failed to create step_record for step %s and record %s: %w", stepCode, recordCode, err)
 			}
 
-			createdStepRecords++
+			if err := countLink(&recordStats, result); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit step associations: %w", err)
+		return nil, fmt.Errorf("failed to commit step associations: %w", err)
+	}
+
+	return []types.TableStats{headerTypeStats, recordStats}, nil
+}
+
+func loadCodeMap(ctx context.Context, tx Tx, query string, what string, args ...interface{}) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", what, err)
+	}
+	defer rows.Close()
+
+	codes := make(map[string]string)
+	for rows.Next() {
+		var id, code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", what, err)
+		}
+		codes[code] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate %s: %w", what, err)
 	}
+	return codes, nil
+}
 
+func countLink(stats *types.TableStats, result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		stats.Unchanged++
+	} else {
+		stats.Inserted++
+	}
 	return nil
 }
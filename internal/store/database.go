@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io/fs"
@@ -11,7 +12,7 @@ import (
 	"github.com/pressly/goose/v3"
 )
 
-func Open() (*sql.DB, error) {
+func Open(ctx context.Context) (*sql.DB, error) {
 	requiredEnvVars := []string{"DB_HOST", "DB_USER", "DB_PASS", "DB_NAME", "DB_PORT", "DB_SSL_MODE"}
 	for _, envVar := range requiredEnvVars {
 		if os.Getenv(envVar) == "" {
@@ -46,7 +47,7 @@ func Open() (*sql.DB, error) {
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
 
-	if err := waitForDatabase(db); err != nil {
+	if err := waitForDatabase(ctx, db); err != nil {
 		return nil, err
 	}
 
@@ -54,7 +55,7 @@ func Open() (*sql.DB, error) {
 	return db, nil
 }
 
-func waitForDatabase(db *sql.DB) error {
+func waitForDatabase(ctx context.Context, db *sql.DB) error {
 	const (
 		maxAttempts    = 10
 		initialBackoff = 500 * time.Millisecond
@@ -64,14 +65,18 @@ func waitForDatabase(db *sql.DB) error {
 	backoff := initialBackoff
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		err := db.Ping()
+		err := db.PingContext(ctx)
 		if err == nil {
 			return nil
 		}
 
 		lastErr = err
 		fmt.Printf("Waiting for database (attempt %d/%d): %v\n", attempt, maxAttempts, err)
-		time.Sleep(backoff)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Error waiting for db: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
 
 		if backoff < maxBackoff {
 			backoff *= 2
@@ -84,23 +89,41 @@ func waitForDatabase(db *sql.DB) error {
 	return fmt.Errorf("Error pinging db after %d attempts: %w", maxAttempts, lastErr)
 }
 
-func MigrateFS(db *sql.DB, migrationsFS fs.FS, dir string) error {
+func MigrateFS(ctx context.Context, db *sql.DB, migrationsFS fs.FS, dir string) error {
 	goose.SetBaseFS(migrationsFS)
 	defer func() {
 		goose.SetBaseFS(nil)
 	}()
-	return Migrate(db, dir)
+	return Migrate(ctx, db, dir)
 }
 
-func Migrate(db *sql.DB, dir string) error {
+func Migrate(ctx context.Context, db *sql.DB, dir string) error {
 	err := goose.SetDialect("postgres")
 	if err != nil {
 		return fmt.Errorf("migrate: set dialect: %w", err)
 	}
 
-	err = goose.Up(db, dir)
+	err = goose.UpContext(ctx, db, dir)
 	if err != nil {
 		return fmt.Errorf("migrate: goose up: %w", err)
 	}
 	return nil
 }
+
+func MigrateCommandFS(ctx context.Context, db *sql.DB, migrationsFS fs.FS, dir string, command string, args ...string) error {
+	goose.SetBaseFS(migrationsFS)
+	defer func() {
+		goose.SetBaseFS(nil)
+	}()
+
+	err := goose.SetDialect("postgres")
+	if err != nil {
+		return fmt.Errorf("migrate: set dialect: %w", err)
+	}
+
+	err = goose.RunContext(ctx, command, db, dir, args...)
+	if err != nil {
+		return fmt.Errorf("migrate: goose %s: %w", command, err)
+	}
+	return nil
+}
@@ -3,196 +3,172 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/lantoniomiranda/shitreader/internal/types"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
+// PostgresEntryStore is shared by concurrent imports; mu guards the caches.
 type PostgresEntryStore struct {
-	db *sql.DB
+	txManager *TxManager
 
-	countryPTId    string
-	districtCache  map[string]string
-	municipalCache map[string]string
+	mu          sync.Mutex
+	parentCache map[string]map[string]string
 
 	tableVersionCache map[string]string
 	catalogCache      map[string]string
 }
 
-func NewPostgresEntryStore(db *sql.DB) *PostgresEntryStore {
+func NewPostgresEntryStore(txManager *TxManager) *PostgresEntryStore {
 	return &PostgresEntryStore{
-		db:                db,
+		txManager:         txManager,
+		parentCache:       make(map[string]map[string]string),
 		tableVersionCache: make(map[string]string),
 		catalogCache:      make(map[string]string),
 	}
 }
 
 type EntryStore interface {
-	BeginTx(ctx context.Context) (*sql.Tx, error)
-	SaveBatch(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error
+	BeginTx(ctx context.Context) (Tx, error)
+	SaveHeader(ctx context.Context, tx Tx, tableName string, header types.TableHeader) error
+	SaveBatch(ctx context.Context, tx Tx, entries []types.Entry, tableName string) (types.TableStats, error)
+	SaveQuarantine(ctx context.Context, tx Tx, runID string, block types.QuarantinedBlock) error
+	DeleteMissing(ctx context.Context, tx Tx, tableName string, tableCode string, version string, keep []string) ([]types.DeletedRow, error)
+	ResolveVersion(ctx context.Context, tx Tx, table string, versions types.Versions) (string, error)
 }
 
 const batchSize = 500
 
-func (s *PostgresEntryStore) BeginTx(ctx context.Context) (*sql.Tx, error) {
-	return s.db.BeginTx(ctx, nil)
+func (s *PostgresEntryStore) BeginTx(ctx context.Context) (Tx, error) {
+	return s.txManager.BeginTx(ctx)
 }
 
-func (s *PostgresEntryStore) SaveBatch(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	if len(entries) == 0 {
-		return nil
-	}
-
-	switch tableName {
-	case "countries":
-		return s.batchInsertCountries(ctx, tx, entries, tableName)
-	case "districts":
-		return s.batchInsertDistricts(ctx, tx, entries, tableName)
-	case "municipalities":
-		return s.batchInsertMunicipalities(ctx, tx, entries, tableName)
-	case "parishes":
-		return s.batchInsertParishes(ctx, tx, entries, tableName)
-	case "ine_zones":
-		return s.batchInsertINEZones(ctx, tx, entries, tableName)
-	case "steps", "records", "fields":
-		return s.batchInsertStructural(ctx, tx, entries, tableName)
-	default:
-		return s.batchInsertCatalog(ctx, tx, entries, tableName)
+// SaveHeader records the official title of a table from its header row, on
+// the table version and, for catalogs, on the catalog along with its table
+// code. A header without a version only titles the catalog. An imported
+// valid from date never replaces one that was already set, and rows whose
+// title and dates did not change are left untouched.
+func (s *PostgresEntryStore) SaveHeader(ctx context.Context, tx Tx, tableName string, header types.TableHeader) error {
+	tableCode, version, title := header.TableCode, header.Version, header.Title
+	if types.SpecFor(tableName).Catalog {
+		var id string
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO catalogs (slug, name, table_code, title) VALUES ($1, COALESCE(NULLIF($3, ''), $1), $2, NULLIF($3, ''))
+			ON CONFLICT (slug) DO UPDATE
+			SET name = COALESCE(EXCLUDED.title, catalogs.name), table_code = EXCLUDED.table_code,
+				title = COALESCE(EXCLUDED.title, catalogs.title), updated_at = NOW()
+			WHERE (catalogs.name, catalogs.table_code, catalogs.title)
+				IS DISTINCT FROM (COALESCE(EXCLUDED.title, catalogs.name), EXCLUDED.table_code, COALESCE(EXCLUDED.title, catalogs.title))
+			RETURNING id
+		`, tableName, tableCode, title).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx, `SELECT id FROM catalogs WHERE slug = $1`, tableName).Scan(&id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save catalog %s: %w", tableName, err)
+		}
+		s.mu.Lock()
+		s.catalogCache[tableName] = id
+		s.mu.Unlock()
 	}
-}
 
-func (s *PostgresEntryStore) getTableVersionID(ctx context.Context, tx *sql.Tx, tableCode, version string) (string, error) {
-	key := tableCode + "|" + version
-	if id, ok := s.tableVersionCache[key]; ok {
-		return id, nil
+	if version == "" {
+		return nil
 	}
-
 	var id string
-	err := tx.QueryRowContext(ctx, `SELECT id FROM table_versions WHERE table_code = $1 AND version = $2`, tableCode, version).Scan(&id)
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO table_versions (table_code, version, title, valid_from) VALUES ($1, $2, NULLIF($3, ''), $4)
+		ON CONFLICT (table_code, version) DO UPDATE
+		SET title = COALESCE(EXCLUDED.title, table_versions.title),
+			valid_from = COALESCE(table_versions.valid_from, EXCLUDED.valid_from), updated_at = NOW()
+		WHERE (table_versions.title, table_versions.valid_from)
+			IS DISTINCT FROM (COALESCE(EXCLUDED.title, table_versions.title), COALESCE(table_versions.valid_from, EXCLUDED.valid_from))
+		RETURNING id
+	`, tableCode, version, title, header.ValidFrom).Scan(&id)
 	if err == sql.ErrNoRows {
 		err = tx.QueryRowContext(ctx, `
-			INSERT INTO table_versions (table_code, version) VALUES ($1, $2)
-			ON CONFLICT (table_code, version) DO UPDATE SET updated_at = NOW()
-			RETURNING id
+			SELECT id FROM table_versions WHERE table_code = $1 AND version = $2
 		`, tableCode, version).Scan(&id)
 	}
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve table version for %s %s: %w", tableCode, version, err)
+		return fmt.Errorf("failed to save table version %s %s: %w", tableCode, version, err)
 	}
-
-	s.tableVersionCache[key] = id
-	return id, nil
+	s.mu.Lock()
+	s.tableVersionCache[tableCode+"|"+version] = id
+	s.mu.Unlock()
+	return nil
 }
 
-func (s *PostgresEntryStore) getCatalogID(ctx context.Context, tx *sql.Tx, slug string) (string, error) {
-	if id, ok := s.catalogCache[slug]; ok {
-		return id, nil
-	}
-
-	var id string
-	err := tx.QueryRowContext(ctx, `SELECT id FROM catalogs WHERE slug = $1`, slug).Scan(&id)
-	if err == sql.ErrNoRows {
-		name := strings.ReplaceAll(slug, "_", " ")
-		c := cases.Title(language.English)
-		name = c.String(name)
-		err = tx.QueryRowContext(ctx, `
-			INSERT INTO catalogs (slug, name) VALUES ($1, $2)
-			ON CONFLICT (slug) DO UPDATE SET updated_at = NOW()
-			RETURNING id
-		`, slug, name).Scan(&id)
-	}
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve catalog for %s: %w", slug, err)
+// SaveBatch upserts entries of one table, laid out as declared by the
+// table's spec. Each entry is stored under its own table version.
+func (s *PostgresEntryStore) SaveBatch(ctx context.Context, tx Tx, entries []types.Entry, tableName string) (types.TableStats, error) {
+	if len(entries) == 0 {
+		return types.TableStats{}, nil
 	}
 
-	s.catalogCache[slug] = id
-	return id, nil
-}
+	spec := types.SpecFor(tableName)
 
-func (s *PostgresEntryStore) batchInsertStructural(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
 	seen := make(map[string]bool)
 	uniqueEntries := make([]types.Entry, 0, len(entries))
 	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
+		key := e.Version + "|" + e.Values[spec.Key]
+		if !seen[key] {
+			seen[key] = true
 			uniqueEntries = append(uniqueEntries, e)
 		}
 	}
+	stats := types.TableStats{Skipped: len(entries) - len(uniqueEntries)}
 	entries = uniqueEntries
 
-	if len(entries) == 0 {
-		return nil
-	}
-
-	first := entries[0]
-	tvId, err := s.getTableVersionID(ctx, tx, first.Table, first.Version)
-	if err != nil {
-		return err
-	}
-
-	cols := "(table_version_id, code, description)"
-	colsPerRow := 3
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", base+1, base+2, base+3))
-			args = append(args, tvId, e.Code, e.Description)
-		}
-
-		conflictTarget := "(table_version_id, code)"
-		updateSet := "SET description = EXCLUDED.description, updated_at = NOW()"
-
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
-
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
+	var fixed []interface{}
+	cols := make([]string, 0, len(spec.Columns)+3)
+	if spec.Catalog {
+		catalogId, err := s.getCatalogID(ctx, tx, tableName)
+		if err != nil {
+			return stats, err
 		}
+		cols = append(cols, "catalog_id")
+		fixed = append(fixed, catalogId)
 	}
-	return nil
-}
 
-func (s *PostgresEntryStore) batchInsertCatalog(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
-			uniqueEntries = append(uniqueEntries, e)
+	cols = append(cols, "table_version_id")
+
+	var updated []string
+	for _, c := range spec.Columns {
+		cols = append(cols, c.Name)
+		if !contains(spec.ConflictKey, c.Name) {
+			updated = append(updated, c.Name)
 		}
 	}
-	entries = uniqueEntries
 
-	if len(entries) == 0 {
-		return nil
-	}
+	// Clearing deleted_at brings back rows that return to the source.
+	updated = append(updated, "deleted_at")
 
-	catalogId, err := s.getCatalogID(ctx, tx, tableName)
-	if err != nil {
-		return err
+	var parents map[string]string
+	if spec.Parent != nil {
+		var err error
+		parents, err = s.parentIDs(ctx, tx, spec.Parent)
+		if err != nil {
+			return stats, err
+		}
+		cols = append(cols, spec.Parent.Column)
+		updated = append(updated, spec.Parent.Column)
 	}
 
-	first := entries[0]
-	tvId, err := s.getTableVersionID(ctx, tx, first.Table, first.Version)
-	if err != nil {
-		return err
+	set := make([]string, 0, len(updated)+1)
+	current := make([]string, 0, len(updated))
+	excluded := make([]string, 0, len(updated))
+	for _, c := range updated {
+		set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", c, c))
+		current = append(current, spec.Relation+"."+c)
+		excluded = append(excluded, "EXCLUDED."+c)
 	}
+	set = append(set, "updated_at = NOW()")
 
-	cols := "(catalog_id, table_version_id, code, description)"
-	colsPerRow := 4
-
+	colsPerRow := len(cols)
 	for start := 0; start < len(entries); start += batchSize {
 		end := start + batchSize
 		if end > len(entries) {
@@ -203,336 +179,285 @@ func (s *PostgresEntryStore) batchInsertCatalog(ctx context.Context, tx *sql.Tx,
 		placeholders := make([]string, 0, len(batch))
 		args := make([]interface{}, 0, len(batch)*colsPerRow)
 		for i, e := range batch {
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-			args = append(args, catalogId, tvId, e.Code, e.Description)
-		}
+			params := make([]string, colsPerRow)
+			for j := range params {
+				params[j] = fmt.Sprintf("$%d", i*colsPerRow+j+1)
+			}
+			placeholders = append(placeholders, "("+strings.Join(params, ", ")+")")
 
-		conflictTarget := "(catalog_id, table_version_id, code)"
-		updateSet := "SET description = EXCLUDED.description, updated_at = NOW()"
+			tvId, err := s.getTableVersionID(ctx, tx, e.Table, e.Version)
+			if err != nil {
+				return stats, err
+			}
+			args = append(args, fixed...)
+			args = append(args, tvId)
+			for _, c := range spec.Columns {
+				args = append(args, e.Values[c.Name])
+			}
+			if spec.Parent != nil {
+				parentId, err := parentFor(spec.Parent, parents, e.Values[spec.Key])
+				if err != nil {
+					return stats, err
+				}
+				args = append(args, parentId)
+			}
+		}
 
-		query := fmt.Sprintf("INSERT INTO catalog_values %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s) DO UPDATE SET %s WHERE (%s) IS DISTINCT FROM (%s) RETURNING (xmax = 0)",
+			spec.Relation, strings.Join(cols, ", "), strings.Join(placeholders, ", "), strings.Join(spec.ConflictKey, ", "),
+			strings.Join(set, ", "), strings.Join(current, ", "), strings.Join(excluded, ", "))
 
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into catalog_values (slug=%s): %w", tableName, err)
+		counts, err := execUpsert(ctx, tx, query, len(batch), args)
+		if err != nil {
+			return stats, fmt.Errorf("batch insert into %s (%s): %w", spec.Relation, tableName, err)
 		}
+		stats.Add(counts)
 	}
-	return nil
+	s.dropParents(spec.Relation)
+	return stats, nil
 }
 
-func (s *PostgresEntryStore) batchInsertCountries(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
-			uniqueEntries = append(uniqueEntries, e)
-		}
-	}
-	entries = uniqueEntries
+// DeleteMissing soft-deletes the rows of a table version whose key is not in
+// keep and returns them.
+func (s *PostgresEntryStore) DeleteMissing(ctx context.Context, tx Tx, tableName string, tableCode string, version string, keep []string) ([]types.DeletedRow, error) {
+	spec := types.SpecFor(tableName)
 
-	if len(entries) == 0 {
-		return nil
-	}
-
-	tvId, err := s.getTableVersionID(ctx, tx, entries[0].Table, entries[0].Version)
+	tvId, err := s.getTableVersionID(ctx, tx, tableCode, version)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	cols := "(table_version_id, code, name)"
-	colsPerRow := 3
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", base+1, base+2, base+3))
-			args = append(args, tvId, e.Code, e.Name)
+	args := []interface{}{tvId, keep}
+	where := ""
+	if spec.Catalog {
+		catalogId, err := s.getCatalogID(ctx, tx, tableName)
+		if err != nil {
+			return nil, err
 		}
+		args = append(args, catalogId)
+		where = " AND catalog_id = $3"
+	}
 
-		conflictTarget := "(table_version_id, code)"
-		updateSet := "SET name = EXCLUDED.name, updated_at = NOW()"
-
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
+	query := fmt.Sprintf(`
+		UPDATE %s SET deleted_at = NOW(), updated_at = NOW()
+		WHERE table_version_id = $1 AND deleted_at IS NULL AND NOT (%s = ANY($2::text[]))%s
+		RETURNING %s, COALESCE(%s, '')
+	`, spec.Relation, spec.Key, where, spec.Key, spec.Label)
 
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
-		}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete missing rows of %s %s: %w", tableCode, version, err)
 	}
-	return nil
-}
+	defer rows.Close()
 
-func (s *PostgresEntryStore) batchInsertDistricts(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
-			uniqueEntries = append(uniqueEntries, e)
+	var deleted []types.DeletedRow
+	for rows.Next() {
+		d := types.DeletedRow{TableCode: tableCode, Version: version}
+		if err := rows.Scan(&d.Code, &d.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted row of %s: %w", tableCode, err)
 		}
+		deleted = append(deleted, d)
 	}
-	entries = uniqueEntries
-
-	if len(entries) == 0 {
-		return nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete missing rows of %s %s: %w", tableCode, version, err)
 	}
+	s.dropParents(spec.Relation)
+	return deleted, nil
+}
 
-	if s.countryPTId == "" {
-		err := tx.QueryRowContext(ctx, `SELECT id FROM countries WHERE code = 'PT' AND deleted_at IS NULL`).Scan(&s.countryPTId)
-		if err != nil {
-			return fmt.Errorf("querying country PT: %w", err)
-		}
-	}
+func (s *PostgresEntryStore) ResolveVersion(ctx context.Context, tx Tx, table string, versions types.Versions) (string, error) {
+	return resolveVersion(ctx, tx, table, versions)
+}
 
-	tvId, err := s.getTableVersionID(ctx, tx, entries[0].Table, entries[0].Version)
+func (s *PostgresEntryStore) SaveQuarantine(ctx context.Context, tx Tx, runID string, block types.QuarantinedBlock) error {
+	rows, err := json.Marshal(block.Rows)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to encode quarantined rows for %s: %w", block.TableCode, err)
 	}
 
-	cols := "(table_version_id, code, name, country_id)"
-	colsPerRow := 4
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-			args = append(args, tvId, e.Code, e.Name, s.countryPTId)
-		}
-
-		conflictTarget := "(table_version_id, code)"
-		updateSet := "SET name = EXCLUDED.name, updated_at = NOW()"
-
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
-
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
-		}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO quarantined_blocks (import_run_id, table_code, version, header_description, source_file, sheet, first_row, last_row, rows)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, runID, block.TableCode, block.Version, block.Description, block.SourceFile, block.Sheet, block.FirstRow, block.LastRow, rows)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine block %s: %w", block.TableCode, err)
 	}
 	return nil
 }
 
-func (s *PostgresEntryStore) batchInsertMunicipalities(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
-			uniqueEntries = append(uniqueEntries, e)
-		}
-	}
-	entries = uniqueEntries
-
-	if len(entries) == 0 {
-		return nil
+func (s *PostgresEntryStore) getTableVersionID(ctx context.Context, tx Tx, tableCode, version string) (string, error) {
+	key := tableCode + "|" + version
+	s.mu.Lock()
+	id, ok := s.tableVersionCache[key]
+	s.mu.Unlock()
+	if ok {
+		return id, nil
 	}
 
-	if s.districtCache == nil {
-		s.districtCache = make(map[string]string)
-		rows, err := tx.QueryContext(ctx, `SELECT id, code FROM districts WHERE deleted_at IS NULL`)
-		if err != nil {
-			return fmt.Errorf("loading districts cache: %w", err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var id, code string
-			if err := rows.Scan(&id, &code); err != nil {
-				return fmt.Errorf("scanning district: %w", err)
-			}
-			if len(code) >= 2 {
-				s.districtCache[code[:2]] = id
-			}
-		}
+	err := tx.QueryRowContext(ctx, `SELECT id FROM table_versions WHERE table_code = $1 AND version = $2`, tableCode, version).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO table_versions (table_code, version) VALUES ($1, $2)
+			ON CONFLICT (table_code, version) DO UPDATE SET updated_at = NOW()
+			RETURNING id
+		`, tableCode, version).Scan(&id)
 	}
-
-	tvId, err := s.getTableVersionID(ctx, tx, entries[0].Table, entries[0].Version)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to resolve table version for %s %s: %w", tableCode, version, err)
 	}
 
-	cols := "(table_version_id, code, name, district_id)"
-	colsPerRow := 4
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			prefix := e.Code
-			if len(e.Code) >= 2 {
-				prefix = e.Code[:2]
-			}
-			districtId, ok := s.districtCache[prefix]
-			if !ok {
-				return fmt.Errorf("district not found for municipality code %s (prefix %s)", e.Code, prefix)
-			}
-
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-			args = append(args, tvId, e.Code, e.Name, districtId)
-		}
-
-		conflictTarget := "(table_version_id, code)"
-		updateSet := "SET name = EXCLUDED.name, updated_at = NOW()"
+	s.mu.Lock()
+	s.tableVersionCache[key] = id
+	s.mu.Unlock()
+	return id, nil
+}
 
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
+func (s *PostgresEntryStore) getCatalogID(ctx context.Context, tx Tx, slug string) (string, error) {
+	s.mu.Lock()
+	id, ok := s.catalogCache[slug]
+	s.mu.Unlock()
+	if ok {
+		return id, nil
+	}
 
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
-		}
+	err := tx.QueryRowContext(ctx, `SELECT id FROM catalogs WHERE slug = $1`, slug).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO catalogs (slug, name) VALUES ($1, $1)
+			ON CONFLICT (slug) DO UPDATE SET updated_at = NOW()
+			RETURNING id
+		`, slug).Scan(&id)
 	}
-	return nil
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve catalog for %s: %w", slug, err)
+	}
+
+	s.mu.Lock()
+	s.catalogCache[slug] = id
+	s.mu.Unlock()
+	return id, nil
 }
 
-func (s *PostgresEntryStore) batchInsertParishes(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.Code] {
-			seen[e.Code] = true
-			uniqueEntries = append(uniqueEntries, e)
-		}
+// parentIDs loads the ids of the parent rows in the parent table's current
+// version, keyed by code or, with PrefixLen, by code prefix. They are cached
+// by version, so a promotion is picked up, and dropped when the parent table
+// is written. The query runs outside the lock, so concurrent imports do not
+// wait on each other; the maps are never modified after they are cached.
+func (s *PostgresEntryStore) parentIDs(ctx context.Context, tx Tx, parent *types.ParentSpec) (map[string]string, error) {
+	versionID, err := resolveVersion(ctx, tx, parent.Relation, nil)
+	if err != nil {
+		return nil, err
 	}
-	entries = uniqueEntries
+	key := fmt.Sprintf("%s|%s|%s|%d", parent.Relation, versionID, parent.Code, parent.PrefixLen)
 
-	if len(entries) == 0 {
-		return nil
+	s.mu.Lock()
+	ids, ok := s.parentCache[key]
+	s.mu.Unlock()
+	if ok {
+		return ids, nil
 	}
 
-	if s.municipalCache == nil {
-		s.municipalCache = make(map[string]string)
-		rows, err := tx.QueryContext(ctx, `SELECT id, code FROM municipalities WHERE deleted_at IS NULL`)
-		if err != nil {
-			return fmt.Errorf("loading municipalities cache: %w", err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var id, code string
-			if err := rows.Scan(&id, &code); err != nil {
-				return fmt.Errorf("scanning municipality: %w", err)
-			}
-			if len(code) >= 4 {
-				s.municipalCache[code[:4]] = id
-			}
-		}
+	query := fmt.Sprintf("SELECT id, code FROM %s WHERE table_version_id = $1 AND deleted_at IS NULL", parent.Relation)
+	args := []interface{}{versionID}
+	if parent.Code != "" {
+		query += " AND code = $2"
+		args = append(args, parent.Code)
 	}
 
-	tvId, err := s.getTableVersionID(ctx, tx, entries[0].Table, entries[0].Version)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("loading %s cache: %w", parent.Relation, err)
 	}
+	defer rows.Close()
 
-	cols := "(table_version_id, code, name, municipality_id)"
-	colsPerRow := 4
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
+	ids = make(map[string]string)
+	for rows.Next() {
+		var id, code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", parent.Relation, err)
 		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			prefix := e.Code
-			if len(e.Code) >= 4 {
-				prefix = e.Code[:4]
-			}
-			municipalId, ok := s.municipalCache[prefix]
-			if !ok {
-				return fmt.Errorf("municipality not found for parish code %s (prefix %s)", e.Code, prefix)
-			}
-
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-			args = append(args, tvId, e.Code, e.Name, municipalId)
+		if parent.PrefixLen == 0 {
+			ids[code] = id
+		} else if len(code) >= parent.PrefixLen {
+			ids[code[:parent.PrefixLen]] = id
 		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("loading %s cache: %w", parent.Relation, err)
+	}
 
-		conflictTarget := "(table_version_id, code)"
-		updateSet := "SET name = EXCLUDED.name, updated_at = NOW()"
-
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
+	s.mu.Lock()
+	s.parentCache[key] = ids
+	s.mu.Unlock()
+	return ids, nil
+}
 
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
+// dropParents forgets the cached parent ids read from relation, once rows of
+// it were written.
+func (s *PostgresEntryStore) dropParents(relation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.parentCache {
+		if strings.HasPrefix(key, relation+"|") {
+			delete(s.parentCache, key)
 		}
 	}
-	return nil
 }
 
-func (s *PostgresEntryStore) batchInsertINEZones(ctx context.Context, tx *sql.Tx, entries []types.Entry, tableName string) error {
-	seen := make(map[string]bool)
-	uniqueEntries := make([]types.Entry, 0, len(entries))
-	for _, e := range entries {
-		if !seen[e.ZoneCode] {
-			seen[e.ZoneCode] = true
-			uniqueEntries = append(uniqueEntries, e)
+func parentFor(parent *types.ParentSpec, ids map[string]string, code string) (string, error) {
+	if parent.Code != "" {
+		id, ok := ids[parent.Code]
+		if !ok {
+			return "", fmt.Errorf("%s %s not found", parent.Relation, parent.Code)
 		}
+		return id, nil
 	}
-	entries = uniqueEntries
 
-	if len(entries) == 0 {
-		return nil
+	prefix := code
+	if len(code) >= parent.PrefixLen {
+		prefix = code[:parent.PrefixLen]
 	}
-
-	tvId, err := s.getTableVersionID(ctx, tx, entries[0].Table, entries[0].Version)
-	if err != nil {
-		return err
+	id, ok := ids[prefix]
+	if !ok {
+		return "", fmt.Errorf("%s not found for code %s (prefix %s)", parent.Relation, code, prefix)
 	}
+	return id, nil
+}
 
-	cols := "(table_version_id, zone_code, zone_name, zone_name_formatted, ine_municipality_code)"
-	colsPerRow := 5
-
-	for start := 0; start < len(entries); start += batchSize {
-		end := start + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batch := entries[start:end]
-
-		placeholders := make([]string, 0, len(batch))
-		args := make([]interface{}, 0, len(batch)*colsPerRow)
-		for i, e := range batch {
-			base := i * colsPerRow
-			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4, base+5))
-			args = append(args, tvId, e.ZoneCode, e.ZoneName, e.ZoneNameFormatted, e.INEMunicipalityCode)
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
 		}
+	}
+	return false
+}
 
-		conflictTarget := "(table_version_id, zone_code)"
-		updateSet := "SET zone_name = EXCLUDED.zone_name, zone_name_formatted = EXCLUDED.zone_name_formatted, updated_at = NOW()"
+func execUpsert(ctx context.Context, tx Tx, query string, rowCount int, args []interface{}) (types.TableStats, error) {
+	var stats types.TableStats
 
-		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s ON CONFLICT %s DO UPDATE %s",
-			tableName, cols, strings.Join(placeholders, ", "), conflictTarget, updateSet)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
 
-		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-			return fmt.Errorf("batch insert into %s: %w", tableName, err)
+	written := 0
+	for rows.Next() {
+		var inserted bool
+		if err := rows.Scan(&inserted); err != nil {
+			return stats, err
 		}
+		if inserted {
+			stats.Inserted++
+		} else {
+			stats.Updated++
+		}
+		written++
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	stats.Unchanged = rowCount - written
+	return stats, nil
 }
@@ -0,0 +1,404 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/tableversion"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type PostgresQueryStore struct {
+	db *sql.DB
+}
+
+func NewPostgresQueryStore(db *sql.DB) *PostgresQueryStore {
+	return &PostgresQueryStore{
+		db: db,
+	}
+}
+
+type QueryStore interface {
+	ListTableVersions(ctx context.Context) ([]types.TableVersion, error)
+	CurrentVersion(ctx context.Context, tableCode string) (string, error)
+	VersionAsOf(ctx context.Context, tableCode string, date time.Time) (string, error)
+	VersionExists(ctx context.Context, tableCode string, version string) (bool, error)
+	ListValues(ctx context.Context, tableCode string, version string) ([]types.Value, error)
+	ListColumns(ctx context.Context, tableCode string, version string) (map[string]map[string]string, error)
+	LookupValue(ctx context.Context, tableCode string, code string) ([]types.Value, error)
+	History(ctx context.Context, tableCode string, code string) ([]types.Change, error)
+	ListLinks(ctx context.Context, tableCode string, version string) ([]types.Link, error)
+}
+
+type valueSource struct {
+	from       string
+	codeColumn string
+	descColumn string
+}
+
+var tableCodePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// valueSourceFor finds the rows of a table code. Codes missing from
+// types.TableCodeMap may belong to an auto-registered catalog, found by the
+// table code it was registered with.
+func valueSourceFor(tableCode string) (valueSource, error) {
+	tableName, ok := types.TableCodeMap[tableCode]
+	if !ok {
+		if !tableCodePattern.MatchString(tableCode) {
+			return valueSource{}, fmt.Errorf("unknown table code %s", tableCode)
+		}
+		spec := types.SpecFor(tableCode)
+		return valueSource{
+			from:       fmt.Sprintf("%s v JOIN catalogs c ON v.catalog_id = c.id AND c.table_code = '%s'", spec.Relation, tableCode),
+			codeColumn: "v." + spec.Key,
+			descColumn: "v." + spec.Label,
+		}, nil
+	}
+
+	spec := types.SpecFor(tableName)
+	src := valueSource{
+		from:       spec.Relation + " v",
+		codeColumn: "v." + spec.Key,
+		descColumn: "v." + spec.Label,
+	}
+	if spec.Catalog {
+		src.from = fmt.Sprintf("%s v JOIN catalogs c ON v.catalog_id = c.id AND c.slug = '%s'", spec.Relation, tableName)
+	}
+	return src, nil
+}
+
+func (s *PostgresQueryStore) ListTableVersions(ctx context.Context) ([]types.TableVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tv.id, tv.table_code, tv.version, COALESCE(tv.title, ''), tv.valid_from, tv.valid_to,
+			a.table_version_id IS NOT NULL
+		FROM table_versions tv
+		LEFT JOIN active_table_versions a ON a.table_version_id = tv.id
+		WHERE tv.deleted_at IS NULL
+		ORDER BY tv.table_code, version_key(tv.version), tv.version
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []types.TableVersion
+	for rows.Next() {
+		var tv types.TableVersion
+		if err := rows.Scan(&tv.ID, &tv.TableCode, &tv.Version, &tv.Title, &tv.ValidFrom, &tv.ValidTo, &tv.Active); err != nil {
+			return nil, fmt.Errorf("failed to scan table version: %w", err)
+		}
+		tv.Table = types.TableCodeMap[tv.TableCode]
+		versions = append(versions, tv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate table versions: %w", err)
+	}
+
+	for i := range versions {
+		src, err := valueSourceFor(versions[i].TableCode)
+		if err != nil {
+			continue
+		}
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE v.table_version_id = $1 AND v.deleted_at IS NULL`, src.from)
+		if err := s.db.QueryRowContext(ctx, query, versions[i].ID).Scan(&versions[i].Rows); err != nil {
+			return nil, fmt.Errorf("failed to count rows for %s %s: %w", versions[i].TableCode, versions[i].Version, err)
+		}
+	}
+
+	return versions, nil
+}
+
+// CurrentVersion returns the version of a table read by default: the
+// promoted one, or the highest imported one.
+func (s *PostgresQueryStore) CurrentVersion(ctx context.Context, tableCode string) (string, error) {
+	var id, version string
+	err := s.db.QueryRowContext(ctx, tableversion.CurrentQuery, tableCode).Scan(&id, &version)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no versions found for table %s", tableCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve current version for %s: %w", tableCode, err)
+	}
+	return version, nil
+}
+
+// VersionAsOf returns the version of a table that applied on date: the one
+// with the latest start on or before it that had not ended yet.
+func (s *PostgresQueryStore) VersionAsOf(ctx context.Context, tableCode string, date time.Time) (string, error) {
+	var version string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT version FROM table_versions
+		WHERE table_code = $1 AND deleted_at IS NULL
+		  AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY valid_from DESC, version_key(version) DESC, version DESC
+		LIMIT 1
+	`, tableCode, date).Scan(&version)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no version of %s applied on %s", tableCode, date.Format(types.DateLayout))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the version of %s on %s: %w", tableCode, date.Format(types.DateLayout), err)
+	}
+	return version, nil
+}
+
+func (s *PostgresQueryStore) VersionExists(ctx context.Context, tableCode string, version string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM table_versions
+			WHERE table_code = $1 AND version = $2 AND deleted_at IS NULL
+		)
+	`, tableCode, version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check version %s of %s: %w", version, tableCode, err)
+	}
+	return exists, nil
+}
+
+func (s *PostgresQueryStore) ListValues(ctx context.Context, tableCode string, version string) ([]types.Value, error) {
+	src, err := valueSourceFor(tableCode)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM %s
+		JOIN table_versions tv ON v.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND v.deleted_at IS NULL
+		ORDER BY %s
+	`, src.codeColumn, src.descColumn, src.from, src.codeColumn)
+
+	rows, err := s.db.QueryContext(ctx, query, tableCode, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load values for %s %s: %w", tableCode, version, err)
+	}
+	defer rows.Close()
+
+	var values []types.Value
+	for rows.Next() {
+		v := types.Value{TableCode: tableCode, Version: version}
+		if err := rows.Scan(&v.Code, &v.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		values = append(values, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate values: %w", err)
+	}
+
+	return values, nil
+}
+
+// ListColumns returns, by code, the values of a table version's columns other
+// than its code and description, for tables that have any (INE zones).
+func (s *PostgresQueryStore) ListColumns(ctx context.Context, tableCode string, version string) (map[string]map[string]string, error) {
+	src, err := valueSourceFor(tableCode)
+	if err != nil {
+		return nil, err
+	}
+	tableName, ok := types.TableCodeMap[tableCode]
+	if !ok {
+		tableName = tableCode
+	}
+	spec := types.SpecFor(tableName)
+
+	var columns, selected []string
+	for _, c := range spec.Columns {
+		if c.Name != spec.Key && c.Name != spec.Label {
+			columns = append(columns, c.Name)
+			selected = append(selected, fmt.Sprintf("COALESCE(v.%s::text, '')", c.Name))
+		}
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM %s
+		JOIN table_versions tv ON v.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND v.deleted_at IS NULL
+	`, src.codeColumn, strings.Join(selected, ", "), src.from)
+
+	rows, err := s.db.QueryContext(ctx, query, tableCode, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load columns for %s %s: %w", tableCode, version, err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]map[string]string)
+	for rows.Next() {
+		var code string
+		row := make([]string, len(columns))
+		dest := []any{&code}
+		for i := range row {
+			dest = append(dest, &row[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan columns: %w", err)
+		}
+		values[code] = make(map[string]string, len(columns))
+		for i, column := range columns {
+			values[code][column] = row[i]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate columns: %w", err)
+	}
+	return values, nil
+}
+
+func (s *PostgresQueryStore) LookupValue(ctx context.Context, tableCode string, code string) ([]types.Value, error) {
+	src, err := valueSourceFor(tableCode)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT tv.version, %s, %s
+		FROM %s
+		JOIN table_versions tv ON v.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND %s = $2 AND v.deleted_at IS NULL
+		ORDER BY version_key(tv.version), tv.version
+	`, src.codeColumn, src.descColumn, src.from, src.codeColumn)
+
+	rows, err := s.db.QueryContext(ctx, query, tableCode, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s in %s: %w", code, tableCode, err)
+	}
+	defer rows.Close()
+
+	var values []types.Value
+	for rows.Next() {
+		v := types.Value{TableCode: tableCode}
+		if err := rows.Scan(&v.Version, &v.Code, &v.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		values = append(values, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate values: %w", err)
+	}
+
+	return values, nil
+}
+
+// History lists the logged changes to a code in any version of a table,
+// oldest first.
+func (s *PostgresQueryStore) History(ctx context.Context, tableCode string, code string) ([]types.Change, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT cl.id, COALESCE(cl.import_run_id::text, ''), cl.table_name, tv.version, cl.code, cl.operation,
+			cl.old_values, cl.new_values, cl.changed_at
+		FROM change_log cl
+		JOIN table_versions tv ON cl.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND cl.code = $2
+		ORDER BY cl.id
+	`, tableCode, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of %s in %s: %w", code, tableCode, err)
+	}
+	defer rows.Close()
+
+	var changes []types.Change
+	for rows.Next() {
+		var c types.Change
+		var before, after []byte
+		if err := rows.Scan(&c.ID, &c.RunID, &c.Table, &c.Version, &c.Code, &c.Operation, &before, &after, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		if c.Old, err = decodeRow(before); err != nil {
+			return nil, fmt.Errorf("failed to decode change %d: %w", c.ID, err)
+		}
+		if c.New, err = decodeRow(after); err != nil {
+			return nil, fmt.Errorf("failed to decode change %d: %w", c.ID, err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// linkQueries select the links of the rows of a structural table version as
+// code, kind and target code, given its table code and version.
+var linkQueries = map[string]string{
+	types.TABLE_STEPS: `
+		SELECT s.code, 'header type', cv.code
+		FROM step_header_types l
+		JOIN steps s ON l.step_id = s.id
+		JOIN catalog_values cv ON l.header_type_id = cv.id
+		JOIN table_versions tv ON s.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND s.deleted_at IS NULL AND l.deleted_at IS NULL
+		UNION ALL
+		SELECT s.code, 'record', r.code
+		FROM step_records l
+		JOIN steps s ON l.step_id = s.id
+		JOIN records r ON l.record_id = r.id
+		JOIN table_versions tv ON s.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND s.deleted_at IS NULL AND l.deleted_at IS NULL
+	`,
+	types.TABLE_RECORDS: `
+		SELECT r.code, 'record type', cv.code
+		FROM records r
+		JOIN catalog_values cv ON r.record_type_id = cv.id
+		JOIN table_versions tv ON r.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND r.deleted_at IS NULL
+	`,
+	types.TABLE_FIELDS: `
+		SELECT f.code, 'record', r.code
+		FROM fields f
+		JOIN records r ON f.record_id = r.id
+		JOIN table_versions tv ON f.table_version_id = tv.id
+		WHERE tv.table_code = $1 AND tv.version = $2 AND f.deleted_at IS NULL
+	`,
+}
+
+// ListLinks returns the links of a version of a structural table: the header
+// types and records of steps, the record type of records and the record of
+// fields. Other tables have none.
+func (s *PostgresQueryStore) ListLinks(ctx context.Context, tableCode string, version string) ([]types.Link, error) {
+	query, ok := linkQueries[types.TableCodeMap[tableCode]]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, tableCode, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load links of %s %s: %w", tableCode, version, err)
+	}
+	defer rows.Close()
+
+	var links []types.Link
+	for rows.Next() {
+		var l types.Link
+		if err := rows.Scan(&l.Code, &l.Kind, &l.Target); err != nil {
+			return nil, fmt.Errorf("failed to scan link: %w", err)
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate links: %w", err)
+	}
+
+	return links, nil
+}
+
+func decodeRow(data []byte) (map[string]any, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var row map[string]any
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
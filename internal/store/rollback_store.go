@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+// trackedTables are the tables whose changes are logged in change_log and
+// can be rolled back.
+var trackedTables = []string{
+	"catalog_values", "countries", "districts", "municipalities", "parishes", "ine_zones",
+	"steps", "records", "fields",
+	"processes", "process_steps", "step_header_types", "step_records",
+	"table_versions", "catalogs",
+}
+
+type PostgresRollbackStore struct {
+	txManager *TxManager
+}
+
+func NewPostgresRollbackStore(txManager *TxManager) *PostgresRollbackStore {
+	return &PostgresRollbackStore{
+		txManager: txManager,
+	}
+}
+
+type RollbackStore interface {
+	ResolveRun(ctx context.Context, id string) (types.ImportRun, error)
+	Rollback(ctx context.Context, runID string, rollbackRunID string, force bool) ([]types.TableStats, error)
+}
+
+// ResolveRun finds a run by its id or a unique prefix of it, as printed in
+// run summaries.
+func (s *PostgresRollbackStore) ResolveRun(ctx context.Context, id string) (types.ImportRun, error) {
+	rows, err := s.txManager.db.QueryContext(ctx, `
+		SELECT id, kind, source_file, status
+		FROM import_runs
+		WHERE left(id::text, length($1)) = $1
+		LIMIT 2
+	`, strings.ToLower(id))
+	if err != nil {
+		return types.ImportRun{}, fmt.Errorf("failed to find import run %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	var runs []types.ImportRun
+	for rows.Next() {
+		var run types.ImportRun
+		if err := rows.Scan(&run.ID, &run.Kind, &run.SourceFile, &run.Status); err != nil {
+			return types.ImportRun{}, fmt.Errorf("failed to scan import run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return types.ImportRun{}, fmt.Errorf("failed to find import run %s: %w", id, err)
+	}
+
+	switch len(runs) {
+	case 0:
+		return types.ImportRun{}, fmt.Errorf("import run %s not found", id)
+	case 1:
+		return runs[0], nil
+	default:
+		return types.ImportRun{}, fmt.Errorf("import run id %s is ambiguous", id)
+	}
+}
+
+type loggedChange struct {
+	id        int64
+	table     string
+	rowID     string
+	operation string
+	old       []byte
+}
+
+// Rollback undoes the logged changes of a run, newest first, in one
+// transaction: rows it inserted are deleted, rows it updated get their old
+// values back and rows it deleted are inserted again. Unless force is set it
+// refuses when a later change touched the same rows or refers to a row the
+// run inserted, since undoing the run would also undo that change.
+func (s *PostgresRollbackStore) Rollback(ctx context.Context, runID string, rollbackRunID string, force bool) ([]types.TableStats, error) {
+	tx, err := s.txManager.BeginTx(WithRun(ctx, rollbackRunID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if !force {
+		var later int
+		// Besides changes to the same rows, rows later written with a
+		// reference to a row the run inserted (a record type, a table version,
+		// a catalog) would lose it or block the undo with a foreign key error.
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM (
+				SELECT later.id
+				FROM change_log later
+				JOIN change_log own ON own.table_name = later.table_name AND own.row_id = later.row_id
+				WHERE own.import_run_id = $1
+				  AND later.id > own.id
+				  AND later.import_run_id IS DISTINCT FROM $1
+				UNION
+				SELECT later.id
+				FROM change_log later
+				CROSS JOIN LATERAL jsonb_each_text(later.new_values) ref
+				WHERE later.id > (SELECT MIN(id) FROM change_log WHERE import_run_id = $1)
+				  AND later.import_run_id IS DISTINCT FROM $1
+				  AND ref.key <> 'id'
+				  AND ref.value IN (
+					SELECT row_id::text FROM change_log
+					WHERE import_run_id = $1 AND operation = 'INSERT'
+				  )
+			) changes
+		`, runID).Scan(&later)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check later changes: %w", err)
+		}
+		if later > 0 {
+			return nil, fmt.Errorf("%d later changes touch rows of import run %s, rolling it back would undo them too (use --force)", later, runID)
+		}
+	}
+
+	changes, err := loadChanges(ctx, tx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []types.TableStats
+	statsFor := func(table string) *types.TableStats {
+		for i := range stats {
+			if stats[i].Table == table {
+				return &stats[i]
+			}
+		}
+		stats = append(stats, types.TableStats{Table: table})
+		return &stats[len(stats)-1]
+	}
+
+	for _, c := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("rollback stopped, nothing was changed: %w", err)
+		}
+		if !slices.Contains(trackedTables, c.table) {
+			return nil, fmt.Errorf("change %d is on untracked table %s", c.id, c.table)
+		}
+
+		switch c.operation {
+		case "INSERT":
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, c.table), c.rowID)
+			statsFor(c.table).Deleted++
+		case "UPDATE":
+			err = restoreRow(ctx, tx, c)
+			statsFor(c.table).Updated++
+		case "DELETE":
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+				INSERT INTO %s SELECT * FROM jsonb_populate_record(NULL::%s, $1::jsonb)
+			`, c.table, c.table), string(c.old))
+			statsFor(c.table).Inserted++
+		default:
+			err = fmt.Errorf("unknown operation %s", c.operation)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to undo change %d (%s on %s %s): %w", c.id, c.operation, c.table, c.rowID, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE import_runs SET status = $2, rolled_back_by = $3 WHERE id = $1
+	`, runID, types.RunStatusRolledBack, rollbackRunID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark import run %s as rolled back: %w", runID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rollback of import run %s: %w", runID, err)
+	}
+	return stats, nil
+}
+
+func loadChanges(ctx context.Context, tx Tx, runID string) ([]loggedChange, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, table_name, row_id, operation, old_values
+		FROM change_log
+		WHERE import_run_id = $1
+		ORDER BY id DESC
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load changes of import run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var changes []loggedChange
+	for rows.Next() {
+		var c loggedChange
+		if err := rows.Scan(&c.id, &c.table, &c.rowID, &c.operation, &c.old); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load changes of import run %s: %w", runID, err)
+	}
+	return changes, nil
+}
+
+// restoreRow writes the logged old values back over every column of the row.
+func restoreRow(ctx context.Context, tx Tx, c loggedChange) error {
+	var old map[string]json.RawMessage
+	if err := json.Unmarshal(c.old, &old); err != nil {
+		return err
+	}
+
+	set := make([]string, 0, len(old))
+	for column := range old {
+		if column != "id" {
+			set = append(set, fmt.Sprintf("%s = r.%s", quoteIdent(column), quoteIdent(column)))
+		}
+	}
+	slices.Sort(set)
+
+	query := fmt.Sprintf(`
+		UPDATE %s t SET %s
+		FROM jsonb_populate_record(NULL::%s, $1::jsonb) r
+		WHERE t.id = $2
+	`, c.table, strings.Join(set, ", "), c.table)
+	_, err := tx.ExecContext(ctx, query, string(c.old), c.rowID)
+	return err
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
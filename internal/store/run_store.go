@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+type PostgresRunStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRunStore(db *sql.DB) *PostgresRunStore {
+	return &PostgresRunStore{
+		db: db,
+	}
+}
+
+type RunStore interface {
+	StartRun(ctx context.Context, run *types.ImportRun) error
+	FinishRun(ctx context.Context, run *types.ImportRun) error
+	MarkSucceeded(ctx context.Context, runIDs []string) error
+	MarkRolledBack(ctx context.Context, runIDs []string) error
+	LastImportedHash(ctx context.Context, run *types.ImportRun) (string, error)
+	ListRuns(ctx context.Context, limit int) ([]types.ImportRun, error)
+	LatestRefreshes(ctx context.Context) ([]types.TableRefresh, error)
+	ListQuarantined(ctx context.Context) ([]types.QuarantinedBlock, error)
+}
+
+func (s *PostgresRunStore) StartRun(ctx context.Context, run *types.ImportRun) error {
+	run.Status = types.RunStatusRunning
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO import_runs (kind, source_file, sheet, content_hash, table_filter, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, started_at
+	`, run.Kind, run.SourceFile, run.Sheet, run.ContentHash, run.TableFilter, run.Status).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record import run: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresRunStore) FinishRun(ctx context.Context, run *types.ImportRun) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var runError sql.NullString
+	if run.Error != "" {
+		runError = sql.NullString{String: run.Error, Valid: true}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE import_runs
+		SET status = $2, error = $3, options = $4, finished_at = NOW()
+		WHERE id = $1
+		RETURNING finished_at
+	`, run.ID, run.Status, runError, run.Options).Scan(&run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish import run %s: %w", run.ID, err)
+	}
+
+	for _, t := range run.Tables {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO import_run_tables (import_run_id, table_code, table_name, inserted, updated, unchanged, skipped, deleted)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (import_run_id, table_name) DO UPDATE
+			SET inserted = EXCLUDED.inserted, updated = EXCLUDED.updated,
+				unchanged = EXCLUDED.unchanged, skipped = EXCLUDED.skipped, deleted = EXCLUDED.deleted
+		`, run.ID, t.TableCode, t.Table, t.Inserted, t.Updated, t.Unchanged, t.Skipped, t.Deleted)
+		if err != nil {
+			return fmt.Errorf("failed to record statistics for %s: %w", t.Table, err)
+		}
+	}
+
+	if err := saveRowErrors(ctx, tx, run.ID, "error", run.RowErrors); err != nil {
+		return fmt.Errorf("failed to record row errors for import run %s: %w", run.ID, err)
+	}
+	if err := saveRowErrors(ctx, tx, run.ID, "warning", run.Warnings); err != nil {
+		return fmt.Errorf("failed to record warnings for import run %s: %w", run.ID, err)
+	}
+
+	if len(run.Deleted) > 0 {
+		tableCodes := make([]string, 0, len(run.Deleted))
+		versions := make([]string, 0, len(run.Deleted))
+		codes := make([]string, 0, len(run.Deleted))
+		descriptions := make([]string, 0, len(run.Deleted))
+		for _, d := range run.Deleted {
+			tableCodes = append(tableCodes, d.TableCode)
+			versions = append(versions, d.Version)
+			codes = append(codes, d.Code)
+			descriptions = append(descriptions, d.Description)
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO import_run_deletions (import_run_id, table_code, version, code, description)
+			SELECT $1, t, v, c, d FROM unnest($2::text[], $3::text[], $4::text[], $5::text[]) AS r(t, v, c, d)
+		`, run.ID, tableCodes, versions, codes, descriptions)
+		if err != nil {
+			return fmt.Errorf("failed to record deleted rows for import run %s: %w", run.ID, err)
+		}
+	}
+
+	if len(run.TextFixes) > 0 {
+		locations := make([]string, 0, len(run.TextFixes))
+		rules := make([]string, 0, len(run.TextFixes))
+		originals := make([]string, 0, len(run.TextFixes))
+		normalized := make([]string, 0, len(run.TextFixes))
+		for _, f := range run.TextFixes {
+			locations = append(locations, f.Location)
+			rules = append(rules, f.Rules)
+			originals = append(originals, f.Original)
+			normalized = append(normalized, f.Normalized)
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO import_run_fixes (import_run_id, location, rules, original, normalized)
+			SELECT $1, l, r, o, n FROM unnest($2::text[], $3::text[], $4::text[], $5::text[]) AS f(l, r, o, n)
+		`, run.ID, locations, rules, originals, normalized)
+		if err != nil {
+			return fmt.Errorf("failed to record text fixes for import run %s: %w", run.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+func saveRowErrors(ctx context.Context, tx *sql.Tx, runID string, severity string, rowErrors []types.RowError) error {
+	if len(rowErrors) == 0 {
+		return nil
+	}
+	locations := make([]string, 0, len(rowErrors))
+	messages := make([]string, 0, len(rowErrors))
+	for _, e := range rowErrors {
+		locations = append(locations, e.Location)
+		messages = append(messages, e.Message)
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO import_run_errors (import_run_id, severity, location, message)
+		SELECT $1, $2, l, m FROM unnest($3::text[], $4::text[]) AS e(l, m)
+	`, runID, severity, locations, messages)
+	return err
+}
+
+// MarkSucceeded records pending runs as succeeded once the pipeline
+// transaction that holds their changes has been committed.
+func (s *PostgresRunStore) MarkSucceeded(ctx context.Context, runIDs []string) error {
+	if len(runIDs) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE import_runs
+		SET status = $2
+		WHERE id = ANY($1::uuid[]) AND status = $3
+	`, runIDs, types.RunStatusSucceeded, types.RunStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to mark import runs as succeeded: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresRunStore) MarkRolledBack(ctx context.Context, runIDs []string) error {
+	if len(runIDs) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE import_runs
+		SET status = $2
+		WHERE id = ANY($1::uuid[]) AND status = ANY($3::text[])
+	`, runIDs, types.RunStatusRolledBack, []string{types.RunStatusSucceeded, types.RunStatusPending})
+	if err != nil {
+		return fmt.Errorf("failed to mark import runs as rolled back: %w", err)
+	}
+	return nil
+}
+
+// LastImportedHash returns the content hash of the last successful full import
+// of the same file and sheet that was read with the same options.
+func (s *PostgresRunStore) LastImportedHash(ctx context.Context, run *types.ImportRun) (string, error) {
+	var hash string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT content_hash
+		FROM import_runs
+		WHERE kind = $1 AND source_file = $2 AND sheet = $3
+		  AND status = $4 AND table_filter = '' AND options = $5
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`, run.Kind, run.SourceFile, run.Sheet, types.RunStatusSucceeded, run.Options).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load last imported hash for %s: %w", run.SourceFile, err)
+	}
+	return hash, nil
+}
+
+func (s *PostgresRunStore) ListRuns(ctx context.Context, limit int) ([]types.ImportRun, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, kind, source_file, sheet, content_hash, table_filter, status, COALESCE(error, ''), started_at, finished_at
+		FROM import_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load import runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []types.ImportRun
+	index := make(map[string]int)
+	for rows.Next() {
+		var run types.ImportRun
+		if err := rows.Scan(&run.ID, &run.Kind, &run.SourceFile, &run.Sheet, &run.ContentHash, &run.TableFilter,
+			&run.Status, &run.Error, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan import run: %w", err)
+		}
+		index[run.ID] = len(runs)
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate import runs: %w", err)
+	}
+	if len(runs) == 0 {
+		return runs, nil
+	}
+
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+
+	tableRows, err := s.db.QueryContext(ctx, `
+		SELECT import_run_id, table_code, table_name, inserted, updated, unchanged, skipped, deleted
+		FROM import_run_tables
+		WHERE import_run_id = ANY($1::uuid[])
+		ORDER BY table_name
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load import run tables: %w", err)
+	}
+	defer tableRows.Close()
+
+	for tableRows.Next() {
+		var runID string
+		var t types.TableStats
+		if err := tableRows.Scan(&runID, &t.TableCode, &t.Table, &t.Inserted, &t.Updated, &t.Unchanged, &t.Skipped, &t.Deleted); err != nil {
+			return nil, fmt.Errorf("failed to scan import run table: %w", err)
+		}
+		i := index[runID]
+		runs[i].Tables = append(runs[i].Tables, t)
+	}
+	if err := tableRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate import run tables: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (s *PostgresRunStore) LatestRefreshes(ctx context.Context) ([]types.TableRefresh, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (t.table_name)
+			t.table_code, t.table_name, t.inserted, t.updated, t.unchanged, t.skipped, t.deleted,
+			r.id, r.source_file, r.content_hash, r.finished_at
+		FROM import_run_tables t
+		JOIN import_runs r ON t.import_run_id = r.id
+		WHERE r.status = $1
+		ORDER BY t.table_name, r.finished_at DESC
+	`, types.RunStatusSucceeded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load table refreshes: %w", err)
+	}
+	defer rows.Close()
+
+	var refreshes []types.TableRefresh
+	for rows.Next() {
+		var r types.TableRefresh
+		if err := rows.Scan(&r.TableCode, &r.Table, &r.Inserted, &r.Updated, &r.Unchanged, &r.Skipped, &r.Deleted,
+			&r.RunID, &r.SourceFile, &r.ContentHash, &r.RefreshedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan table refresh: %w", err)
+		}
+		refreshes = append(refreshes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate table refreshes: %w", err)
+	}
+
+	return refreshes, nil
+}
+
+func (s *PostgresRunStore) ListQuarantined(ctx context.Context) ([]types.QuarantinedBlock, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (table_code, version)
+			table_code, version, header_description, source_file, sheet, first_row, last_row, rows
+		FROM quarantined_blocks
+		ORDER BY table_code, version, created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quarantined blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []types.QuarantinedBlock
+	for rows.Next() {
+		var b types.QuarantinedBlock
+		var data []byte
+		if err := rows.Scan(&b.TableCode, &b.Version, &b.Description, &b.SourceFile, &b.Sheet, &b.FirstRow, &b.LastRow, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantined block: %w", err)
+		}
+		if err := json.Unmarshal(data, &b.Rows); err != nil {
+			return nil, fmt.Errorf("failed to decode quarantined rows for %s: %w", b.TableCode, err)
+		}
+		blocks = append(blocks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate quarantined blocks: %w", err)
+	}
+
+	return blocks, nil
+}
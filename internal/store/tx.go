@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+// TxManager hands out the transactions used by the stores. While a pipeline
+// transaction is open every BeginTx joins it, so the stores' own commits
+// become no-ops and the pipeline decides whether everything is kept.
+type TxManager struct {
+	db *sql.DB
+
+	mu       sync.Mutex
+	pipeline *sql.Tx
+}
+
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{
+		db: db,
+	}
+}
+
+type runKey struct{}
+
+// WithRun tags the transactions begun with the returned context with an
+// import run, so the change log can tell which run changed a row.
+func WithRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runKey{}, runID)
+}
+
+func (m *TxManager) BeginTx(ctx context.Context) (Tx, error) {
+	m.mu.Lock()
+	pipeline := m.pipeline
+	m.mu.Unlock()
+
+	var tx Tx = joinedTx{pipeline}
+	if pipeline == nil {
+		t, err := m.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		tx = t
+	}
+
+	if runID, ok := ctx.Value(runKey{}).(string); ok {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('shitreader.run_id', $1, true)`, runID); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to tag transaction with import run %s: %w", runID, err)
+		}
+	}
+	return tx, nil
+}
+
+func (m *TxManager) InPipeline() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pipeline != nil
+}
+
+func (m *TxManager) BeginPipeline(ctx context.Context) (Tx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pipeline != nil {
+		return nil, fmt.Errorf("a pipeline transaction is already open")
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin pipeline transaction: %w", err)
+	}
+	m.pipeline = tx
+	return &pipelineTx{Tx: tx, manager: m}, nil
+}
+
+func (m *TxManager) endPipeline() {
+	m.mu.Lock()
+	m.pipeline = nil
+	m.mu.Unlock()
+}
+
+type joinedTx struct {
+	*sql.Tx
+}
+
+func (joinedTx) Commit() error {
+	return nil
+}
+
+func (joinedTx) Rollback() error {
+	return nil
+}
+
+type pipelineTx struct {
+	*sql.Tx
+	manager *TxManager
+}
+
+func (t *pipelineTx) Commit() error {
+	defer t.manager.endPipeline()
+	return t.Tx.Commit()
+}
+
+func (t *pipelineTx) Rollback() error {
+	defer t.manager.endPipeline()
+	return t.Tx.Rollback()
+}
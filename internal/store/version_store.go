@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lantoniomiranda/shitreader/internal/tableversion"
+)
+
+type PostgresVersionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresVersionStore(db *sql.DB) *PostgresVersionStore {
+	return &PostgresVersionStore{
+		db: db,
+	}
+}
+
+type VersionStore interface {
+	SetValidity(ctx context.Context, tableCode string, version string, from time.Time, to *time.Time) error
+	ActiveVersion(ctx context.Context, tableCode string) (string, error)
+	CurrentVersion(ctx context.Context, tableCode string) (string, error)
+	Promote(ctx context.Context, tableCode string, version string) error
+}
+
+func (s *PostgresVersionStore) SetValidity(ctx context.Context, tableCode string, version string, from time.Time, to *time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE table_versions
+		SET valid_from = $3, valid_to = $4, updated_at = NOW()
+		WHERE table_code = $1 AND version = $2 AND deleted_at IS NULL
+	`, tableCode, version, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to set validity of %s %s: %w", tableCode, version, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s %s has not been imported", tableCode, version)
+	}
+	return nil
+}
+
+// ActiveVersion returns the promoted version of a table code, or "" when none
+// was promoted.
+func (s *PostgresVersionStore) ActiveVersion(ctx context.Context, tableCode string) (string, error) {
+	var version string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tv.version
+		FROM active_table_versions a
+		JOIN table_versions tv ON a.table_version_id = tv.id
+		WHERE a.table_code = $1 AND tv.deleted_at IS NULL
+	`, tableCode).Scan(&version)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load active version of %s: %w", tableCode, err)
+	}
+	return version, nil
+}
+
+// CurrentVersion returns the version of a table code read by default, the
+// promoted one or the highest imported one, or "" when none was imported.
+func (s *PostgresVersionStore) CurrentVersion(ctx context.Context, tableCode string) (string, error) {
+	var id, version string
+	err := s.db.QueryRowContext(ctx, tableversion.CurrentQuery, tableCode).Scan(&id, &version)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve current version for %s: %w", tableCode, err)
+	}
+	return version, nil
+}
+
+func (s *PostgresVersionStore) Promote(ctx context.Context, tableCode string, version string) error {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO active_table_versions (table_code, table_version_id)
+		SELECT table_code, id FROM table_versions
+		WHERE table_code = $1 AND version = $2 AND deleted_at IS NULL
+		ON CONFLICT (table_code) DO UPDATE
+		SET table_version_id = EXCLUDED.table_version_id, promoted_at = NOW()
+	`, tableCode, version)
+	if err != nil {
+		return fmt.Errorf("failed to promote %s %s: %w", tableCode, version, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s %s has not been imported", tableCode, version)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lantoniomiranda/shitreader/internal/tableversion"
+	"github.com/lantoniomiranda/shitreader/internal/types"
+)
+
+// resolveVersion returns the id of the version of table pinned in versions,
+// or of its current version.
+func resolveVersion(ctx context.Context, tx Tx, table string, versions types.Versions) (string, error) {
+	tableCode := types.TableCodeOf(table)
+	var id string
+	var err error
+	if version, ok := versions[tableCode]; ok {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM table_versions
+			WHERE table_code = $1 AND version = $2 AND deleted_at IS NULL
+		`, tableCode, version).Scan(&id)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%s %s has not been imported", tableCode, version)
+		}
+	} else {
+		var version string
+		err = tx.QueryRowContext(ctx, tableversion.CurrentQuery, tableCode).Scan(&id, &version)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("no version of %s has been imported", tableCode)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the version of %s: %w", tableCode, err)
+	}
+	return id, nil
+}
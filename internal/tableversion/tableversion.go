@@ -0,0 +1,17 @@
+// Package tableversion holds the SQL that decides which version of a table is
+// read by default. It has no dependencies, so the store and the public catalog
+// package share it without the catalog pulling in the store.
+package tableversion
+
+// CurrentQuery selects the id and version of a table code's current version:
+// the promoted one, or the highest imported one when none was promoted.
+// Versions are compared by their numbers (version_key), so V01.2710 is higher
+// than V01.999.
+const CurrentQuery = `
+	SELECT tv.id, tv.version
+	FROM table_versions tv
+	LEFT JOIN active_table_versions a ON a.table_version_id = tv.id
+	WHERE tv.table_code = $1 AND tv.deleted_at IS NULL
+	ORDER BY a.table_version_id IS NOT NULL DESC, version_key(tv.version) DESC, tv.version DESC
+	LIMIT 1
+`
@@ -0,0 +1,16 @@
+package types
+
+type RecordTypeLink struct {
+	RecordCode     string
+	RecordTypeCode string
+}
+
+type StepLinks struct {
+	StepCode        string
+	HeaderTypeCodes []string
+	RecordCodes     []string
+}
+
+// Versions pins table codes to the version that codes are resolved in when
+// linking rows. Tables that are not pinned use their current version.
+type Versions map[string]string
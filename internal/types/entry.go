@@ -1,19 +1,9 @@
 package types
 
-import "time"
-
+// Entry is a data row of a block. Values is keyed by the column names of the
+// table's spec.
 type Entry struct {
-	Id                  string
-	Table               string
-	Version             string
-	Code                string
-	Description         string
-	Name                string
-	ZoneCode            string
-	ZoneName            string
-	ZoneNameFormatted   string
-	INEMunicipalityCode string
-	CreatedAt           *time.Time
-	UpdatedAt           *time.Time
-	DeletedAt           *time.Time
+	Table   string
+	Version string
+	Values  map[string]string
 }
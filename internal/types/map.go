@@ -202,3 +202,13 @@ var TableCodeMap = map[string]string{
 	"T29000": TABLE_RECIPIENT,
 	"T29100": TABLE_DEADLINE_IDENTIFIERS,
 }
+
+// TableCodeOf returns the table code a table is imported from.
+func TableCodeOf(table string) string {
+	for code, name := range TableCodeMap {
+		if name == table {
+			return code
+		}
+	}
+	return ""
+}
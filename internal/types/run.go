@@ -0,0 +1,120 @@
+package types
+
+import "time"
+
+const (
+	RunKindBlockCatalog = "block-catalog"
+	RunKindProcessSteps = "process-steps"
+	RunKindRecordFields = "record-fields"
+	RunKindRecordTypes  = "record-types"
+	RunKindStepRecords  = "step-records"
+	RunKindRollback     = "rollback"
+)
+
+const (
+	RunStatusRunning    = "running"
+	RunStatusSucceeded  = "succeeded"
+	RunStatusPending    = "pending"
+	RunStatusFailed     = "failed"
+	RunStatusSkipped    = "skipped"
+	RunStatusCancelled  = "cancelled"
+	RunStatusRolledBack = "rolled_back"
+)
+
+type ImportRun struct {
+	ID          string
+	Kind        string
+	SourceFile  string
+	Sheet       string
+	ContentHash string
+	TableFilter string
+	// Options lists the read options that change what an import writes.
+	Options     string
+	Status      string
+	Error       string
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+	Tables      []TableStats
+	Quarantined []QuarantinedBlock
+	RowErrors   []RowError
+	Warnings    []RowError
+	TextFixes   []TextFix
+	Deleted     []DeletedRow
+}
+
+type TableStats struct {
+	TableCode string
+	Table     string
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Skipped   int
+	Deleted   int
+}
+
+type QuarantinedBlock struct {
+	TableCode   string
+	Version     string
+	Description string
+	SourceFile  string
+	Sheet       string
+	FirstRow    int
+	LastRow     int
+	Rows        [][]string
+}
+
+// RowError is a row that breaks the expected layout of a source and was
+// skipped, or, in ImportRun.Warnings, a cell that was read but looks
+// suspicious. Location points at the offending cell.
+type RowError struct {
+	Location string
+	Message  string
+}
+
+func (e RowError) Error() string {
+	return e.Location + ": " + e.Message
+}
+
+// TextFix is a cell value that was changed by normalization before it was
+// stored. Rules lists the normalization rules that changed it.
+type TextFix struct {
+	Location   string
+	Rules      string
+	Original   string
+	Normalized string
+}
+
+// DeletedRow is a row that was soft-deleted because its code is no longer in
+// the source.
+type DeletedRow struct {
+	TableCode   string
+	Version     string
+	Code        string
+	Description string
+}
+
+type TableRefresh struct {
+	TableStats
+	RunID       string
+	SourceFile  string
+	ContentHash string
+	RefreshedAt time.Time
+}
+
+func (s *TableStats) Add(other TableStats) {
+	s.Inserted += other.Inserted
+	s.Updated += other.Updated
+	s.Unchanged += other.Unchanged
+	s.Skipped += other.Skipped
+	s.Deleted += other.Deleted
+}
+
+func (r *ImportRun) Stats(tableCode string, table string) *TableStats {
+	for i := range r.Tables {
+		if r.Tables[i].Table == table {
+			return &r.Tables[i]
+		}
+	}
+	r.Tables = append(r.Tables, TableStats{TableCode: tableCode, Table: table})
+	return &r.Tables[len(r.Tables)-1]
+}
@@ -0,0 +1,154 @@
+package types
+
+// Sheet columns of a block-format data row. A and B always hold the table
+// code and version, the table's own columns start at C.
+const (
+	ColumnC = 3
+	ColumnD = 4
+	ColumnE = 5
+	ColumnF = 6
+)
+
+// TableSpec declares how the rows of a table are read and stored. Tables
+// without a declaration are catalogs, see SpecFor.
+type TableSpec struct {
+	// Relation is the database table the rows are written to.
+	Relation string
+	// Catalog rows go to catalog_values under the catalog named after the
+	// table.
+	Catalog bool
+	Columns []ColumnSpec
+	// Key is the column that identifies a row within a table version and
+	// Label the column shown as its description.
+	Key         string
+	Label       string
+	ConflictKey []string
+	Parent      *ParentSpec
+}
+
+// ColumnSpec maps a sheet column to a database column. Width, when set, is
+// the number of digits of a numeric code, which is zero-padded back to it.
+type ColumnSpec struct {
+	Name   string
+	Source int
+	Width  int
+}
+
+// ParentSpec links a row to a row of another table, either by a fixed code
+// or by the first PrefixLen characters of the row's key.
+type ParentSpec struct {
+	Column    string
+	Relation  string
+	Code      string
+	PrefixLen int
+}
+
+var codeDescriptionColumns = []ColumnSpec{
+	{Name: "code", Source: ColumnC},
+	{Name: "description", Source: ColumnD},
+}
+
+var TableSpecs = map[string]TableSpec{
+	TABLE_CAE_REV4:             catalogCodeSpec(5),
+	TABLE_SYNTAX_ERRORS:        catalogCodeSpec(3),
+	TABLE_DATA_ERRORS:          catalogCodeSpec(3),
+	TABLE_DELIVERY_POINT_TYPES: catalogCodeSpec(4),
+	TABLE_EQUIPMENT_BRANDS:     catalogCodeSpec(3),
+
+	TABLE_STEPS:   structuralSpec(TABLE_STEPS),
+	TABLE_RECORDS: structuralSpec(TABLE_RECORDS),
+	TABLE_FIELDS:  structuralSpec(TABLE_FIELDS),
+
+	TABLE_COUNTRIES: geoSpec(TABLE_COUNTRIES, 0, nil),
+	TABLE_DISTRICTS: geoSpec(TABLE_DISTRICTS, 6, &ParentSpec{
+		Column: "country_id", Relation: TABLE_COUNTRIES, Code: "PT",
+	}),
+	TABLE_MUNICIPALITIES: geoSpec(TABLE_MUNICIPALITIES, 6, &ParentSpec{
+		Column: "district_id", Relation: TABLE_DISTRICTS, PrefixLen: 2,
+	}),
+	TABLE_PARISHES: geoSpec(TABLE_PARISHES, 6, &ParentSpec{
+		Column: "municipality_id", Relation: TABLE_MUNICIPALITIES, PrefixLen: 4,
+	}),
+
+	TABLE_INE_ZONES: {
+		Relation: TABLE_INE_ZONES,
+		Columns: []ColumnSpec{
+			{Name: "zone_code", Source: ColumnC, Width: 6},
+			{Name: "zone_name", Source: ColumnD},
+			{Name: "zone_name_formatted", Source: ColumnE},
+			{Name: "ine_municipality_code", Source: ColumnF, Width: 4},
+		},
+		Key:         "zone_code",
+		Label:       "zone_name",
+		ConflictKey: []string{"table_version_id", "zone_code"},
+	},
+}
+
+func structuralSpec(relation string) TableSpec {
+	return TableSpec{
+		Relation:    relation,
+		Columns:     codeDescriptionColumns,
+		Key:         "code",
+		Label:       "description",
+		ConflictKey: []string{"table_version_id", "code"},
+	}
+}
+
+func geoSpec(relation string, width int, parent *ParentSpec) TableSpec {
+	return TableSpec{
+		Relation: relation,
+		Columns: []ColumnSpec{
+			{Name: "name", Source: ColumnC},
+			{Name: "code", Source: ColumnD, Width: width},
+		},
+		Key:         "code",
+		Label:       "name",
+		ConflictKey: []string{"table_version_id", "code"},
+		Parent:      parent,
+	}
+}
+
+var catalogSpec = TableSpec{
+	Relation:    "catalog_values",
+	Catalog:     true,
+	Columns:     codeDescriptionColumns,
+	Key:         "code",
+	Label:       "description",
+	ConflictKey: []string{"catalog_id", "table_version_id", "code"},
+}
+
+func catalogCodeSpec(width int) TableSpec {
+	spec := catalogSpec
+	spec.Columns = []ColumnSpec{
+		{Name: "code", Source: ColumnC, Width: width},
+		{Name: "description", Source: ColumnD},
+	}
+	return spec
+}
+
+func SpecFor(table string) TableSpec {
+	if spec, ok := TableSpecs[table]; ok {
+		return spec
+	}
+	return catalogSpec
+}
+
+// ParseEntry reads a data row into an entry laid out by spec.
+func (spec TableSpec) ParseEntry(row []string) Entry {
+	entry := Entry{
+		Table:   cellAt(row, 1),
+		Version: cellAt(row, 2),
+		Values:  make(map[string]string, len(spec.Columns)),
+	}
+	for _, c := range spec.Columns {
+		entry.Values[c.Name] = cellAt(row, c.Source)
+	}
+	return entry
+}
+
+func cellAt(row []string, column int) string {
+	if column-1 < len(row) {
+		return row[column-1]
+	}
+	return ""
+}
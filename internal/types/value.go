@@ -0,0 +1,89 @@
+package types
+
+import "time"
+
+// DateLayout is how dates are written on the command line and in manifests.
+const DateLayout = "2006-01-02"
+
+type TableVersion struct {
+	ID        string
+	TableCode string
+	Version   string
+	Table     string
+	Title     string
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+	Active    bool
+	Rows      int
+}
+
+// TableHeader is what a block's header row says about its table.
+// ValidFrom, when set, is the date the version applies from.
+type TableHeader struct {
+	TableCode string
+	Version   string
+	Title     string
+	ValidFrom *time.Time
+}
+
+type Value struct {
+	TableCode   string `json:"table_code"`
+	Version     string `json:"version"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// Diff lists the codes added, removed and re-described between two versions
+// of a table.
+type Diff struct {
+	TableCode   string        `json:"table_code"`
+	Table       string        `json:"table"`
+	FromVersion string        `json:"from_version"`
+	ToVersion   string        `json:"to_version"`
+	Added       []Value       `json:"added"`
+	Removed     []Value       `json:"removed"`
+	Changed     []ValueChange `json:"changed"`
+	Links       []LinkChange  `json:"links"`
+}
+
+// Change is an entry of the change log: a row of a reference table that was
+// inserted, updated or deleted. Old and New hold the whole row before and
+// after.
+type Change struct {
+	ID        int64
+	RunID     string
+	Table     string
+	Version   string
+	Code      string
+	Operation string
+	Old       map[string]any
+	New       map[string]any
+	ChangedAt time.Time
+}
+
+// ValueChange is a code whose description changed or, when Column is set,
+// whose value in another column of its table did, such as the formatted name
+// of an INE zone.
+type ValueChange struct {
+	Code   string `json:"code"`
+	Column string `json:"column,omitempty"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Link ties a code of a structural table to a code of another table, such as
+// a field to the record it belongs to.
+type Link struct {
+	Code   string
+	Kind   string
+	Target string
+}
+
+// LinkChange lists the targets of one kind of link that a code gained and
+// lost between two versions.
+type LinkChange struct {
+	Code    string   `json:"code"`
+	Link    string   `json:"link"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"cmp"
+	"strings"
+)
+
+// CompareVersions orders versions by their numbers, as the version_key
+// function does in the database: V01.999 comes before V01.2710. Versions with
+// the same numbers are ordered as text.
+func CompareVersions(a, b string) int {
+	na, nb := versionNumbers(a), versionNumbers(b)
+	for i := 0; i < len(na) && i < len(nb); i++ {
+		if c := compareNumbers(na[i], nb[i]); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(len(na), len(nb)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// versionNumbers returns the runs of digits of a version.
+func versionNumbers(version string) []string {
+	var numbers []string
+	start := -1
+	for i := 0; i <= len(version); i++ {
+		digit := i < len(version) && version[i] >= '0' && version[i] <= '9'
+		switch {
+		case digit && start < 0:
+			start = i
+		case !digit && start >= 0:
+			numbers = append(numbers, version[start:i])
+			start = -1
+		}
+	}
+	return numbers
+}
+
+// compareNumbers compares two runs of digits of any length by value.
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if c := cmp.Compare(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
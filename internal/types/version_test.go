@@ -0,0 +1,25 @@
+package types
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"V01.00", "V01.00", 0},
+		{"V01.00", "V01.01", -1},
+		{"V02.00", "V01.99", 1},
+		{"V01.999", "V01.2710", -1},
+		{"V01.2710", "V01.999", 1},
+		{"V1.1", "V01.01", 1},
+		{"V01", "V01.00", -1},
+		{"", "V01.00", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
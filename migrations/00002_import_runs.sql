@@ -0,0 +1,39 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE import_runs (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	kind VARCHAR(50) NOT NULL,
+	source_file TEXT NOT NULL DEFAULT '',
+	sheet VARCHAR(100) NOT NULL DEFAULT '',
+	content_hash VARCHAR(64) NOT NULL DEFAULT '',
+	status VARCHAR(20) NOT NULL,
+	error TEXT NULL,
+	started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	finished_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_import_runs_started_at ON import_runs(started_at);
+
+CREATE TABLE import_run_tables (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	import_run_id UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+	table_code VARCHAR(50) NOT NULL DEFAULT '',
+	table_name VARCHAR(100) NOT NULL,
+	inserted INT NOT NULL DEFAULT 0,
+	updated INT NOT NULL DEFAULT 0,
+	unchanged INT NOT NULL DEFAULT 0,
+	skipped INT NOT NULL DEFAULT 0,
+	CONSTRAINT unique_import_run_table UNIQUE (import_run_id, table_name)
+);
+
+CREATE INDEX idx_import_run_tables_import_run_id ON import_run_tables(import_run_id);
+CREATE INDEX idx_import_run_tables_table_name ON import_run_tables(table_name);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_run_tables CASCADE;
+DROP TABLE IF EXISTS import_runs CASCADE;
+-- +goose StatementEnd
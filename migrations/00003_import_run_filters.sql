@@ -0,0 +1,14 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE import_runs ADD COLUMN table_filter TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_import_runs_source ON import_runs(source_file, sheet, status);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_import_runs_source;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS table_filter;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE quarantined_blocks (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	import_run_id UUID NULL REFERENCES import_runs(id) ON DELETE SET NULL,
+	table_code VARCHAR(50) NOT NULL,
+	version VARCHAR(20) NOT NULL,
+	header_description TEXT NOT NULL DEFAULT '',
+	source_file TEXT NOT NULL,
+	sheet VARCHAR(100) NOT NULL,
+	first_row INT NOT NULL,
+	last_row INT NOT NULL,
+	rows JSONB NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_quarantined_blocks_table_code ON quarantined_blocks(table_code);
+CREATE INDEX idx_quarantined_blocks_import_run_id ON quarantined_blocks(import_run_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS quarantined_blocks CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE import_run_errors (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	import_run_id UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+	location TEXT NOT NULL,
+	message TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_run_errors_import_run_id ON import_run_errors(import_run_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_run_errors CASCADE;
+-- +goose StatementEnd
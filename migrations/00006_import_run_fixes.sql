@@ -0,0 +1,21 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE import_run_fixes (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	import_run_id UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+	location TEXT NOT NULL,
+	rules TEXT NOT NULL,
+	original TEXT NOT NULL,
+	normalized TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_run_fixes_import_run_id ON import_run_fixes(import_run_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_run_fixes CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE import_run_errors
+	ADD COLUMN severity TEXT NOT NULL DEFAULT 'error'
+	CHECK (severity IN ('error', 'warning'));
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+ALTER TABLE import_run_errors DROP COLUMN IF EXISTS severity;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE catalogs
+	ADD COLUMN table_code VARCHAR(50) NULL,
+	ADD COLUMN title TEXT NULL;
+
+ALTER TABLE table_versions
+	ADD COLUMN title TEXT NULL;
+
+CREATE INDEX idx_catalogs_table_code ON catalogs(table_code);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+ALTER TABLE table_versions DROP COLUMN IF EXISTS title;
+ALTER TABLE catalogs DROP COLUMN IF EXISTS title, DROP COLUMN IF EXISTS table_code;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE step_header_types
+	ADD COLUMN table_version_id UUID NULL REFERENCES table_versions(id) ON DELETE CASCADE;
+
+ALTER TABLE step_records
+	ADD COLUMN table_version_id UUID NULL REFERENCES table_versions(id) ON DELETE CASCADE;
+
+ALTER TABLE process_steps
+	ADD COLUMN table_version_id UUID NULL REFERENCES table_versions(id) ON DELETE CASCADE;
+
+UPDATE step_header_types l SET table_version_id = s.table_version_id FROM steps s WHERE l.step_id = s.id;
+UPDATE step_records l SET table_version_id = s.table_version_id FROM steps s WHERE l.step_id = s.id;
+UPDATE process_steps l SET table_version_id = s.table_version_id FROM steps s WHERE l.step_id = s.id;
+
+CREATE INDEX idx_step_header_types_table_version_id ON step_header_types(table_version_id);
+CREATE INDEX idx_step_records_table_version_id ON step_records(table_version_id);
+CREATE INDEX idx_process_steps_table_version_id ON process_steps(table_version_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+ALTER TABLE process_steps DROP COLUMN IF EXISTS table_version_id;
+ALTER TABLE step_records DROP COLUMN IF EXISTS table_version_id;
+ALTER TABLE step_header_types DROP COLUMN IF EXISTS table_version_id;
+-- +goose StatementEnd
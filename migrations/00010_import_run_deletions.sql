@@ -0,0 +1,25 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE import_run_tables
+	ADD COLUMN deleted INT NOT NULL DEFAULT 0;
+
+CREATE TABLE import_run_deletions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	import_run_id UUID NOT NULL REFERENCES import_runs(id) ON DELETE CASCADE,
+	table_code VARCHAR(50) NOT NULL,
+	version VARCHAR(20) NOT NULL,
+	code TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_run_deletions_import_run_id ON import_run_deletions(import_run_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_run_deletions CASCADE;
+ALTER TABLE import_run_tables DROP COLUMN IF EXISTS deleted;
+-- +goose StatementEnd
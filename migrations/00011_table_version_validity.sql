@@ -0,0 +1,19 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE table_versions
+	ADD COLUMN valid_from DATE NULL,
+	ADD COLUMN valid_to DATE NULL,
+	ADD CONSTRAINT table_versions_valid_range CHECK (valid_to IS NULL OR valid_from IS NULL OR valid_to > valid_from);
+
+CREATE INDEX idx_table_versions_validity ON table_versions(table_code, valid_from);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+ALTER TABLE table_versions
+	DROP CONSTRAINT IF EXISTS table_versions_valid_range,
+	DROP COLUMN IF EXISTS valid_to,
+	DROP COLUMN IF EXISTS valid_from;
+-- +goose StatementEnd
@@ -0,0 +1,80 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE change_log (
+	id BIGSERIAL PRIMARY KEY,
+	import_run_id UUID NULL REFERENCES import_runs(id) ON DELETE SET NULL,
+	table_name VARCHAR(100) NOT NULL,
+	row_id UUID NOT NULL,
+	table_version_id UUID NULL,
+	code TEXT NULL,
+	operation VARCHAR(10) NOT NULL,
+	old_values JSONB NULL,
+	new_values JSONB NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX idx_change_log_import_run_id ON change_log(import_run_id);
+CREATE INDEX idx_change_log_code ON change_log(table_version_id, code);
+
+-- log_change records every change to a reference table row, with the import
+-- run that made it when the transaction set shitreader.run_id. Updates that
+-- only touch updated_at are not changes.
+CREATE OR REPLACE FUNCTION log_change() RETURNS trigger AS $$
+DECLARE
+	old_row JSONB;
+	new_row JSONB;
+	current_row JSONB;
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		old_row := to_jsonb(OLD);
+	END IF;
+	IF TG_OP <> 'DELETE' THEN
+		new_row := to_jsonb(NEW);
+	END IF;
+	IF TG_OP = 'UPDATE' AND (old_row - 'updated_at') = (new_row - 'updated_at') THEN
+		RETURN NULL;
+	END IF;
+
+	current_row := COALESCE(new_row, old_row);
+	INSERT INTO change_log (import_run_id, table_name, row_id, table_version_id, code, operation, old_values, new_values)
+	VALUES (
+		NULLIF(current_setting('shitreader.run_id', true), '')::uuid,
+		TG_TABLE_NAME,
+		(current_row->>'id')::uuid,
+		(current_row->>'table_version_id')::uuid,
+		COALESCE(current_row->>'code', current_row->>'zone_code'),
+		TG_OP,
+		old_row,
+		new_row
+	);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER catalog_values_change_log AFTER INSERT OR UPDATE OR DELETE ON catalog_values FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER countries_change_log AFTER INSERT OR UPDATE OR DELETE ON countries FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER districts_change_log AFTER INSERT OR UPDATE OR DELETE ON districts FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER municipalities_change_log AFTER INSERT OR UPDATE OR DELETE ON municipalities FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER parishes_change_log AFTER INSERT OR UPDATE OR DELETE ON parishes FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER ine_zones_change_log AFTER INSERT OR UPDATE OR DELETE ON ine_zones FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER steps_change_log AFTER INSERT OR UPDATE OR DELETE ON steps FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER records_change_log AFTER INSERT OR UPDATE OR DELETE ON records FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER fields_change_log AFTER INSERT OR UPDATE OR DELETE ON fields FOR EACH ROW EXECUTE FUNCTION log_change();
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS fields_change_log ON fields;
+DROP TRIGGER IF EXISTS records_change_log ON records;
+DROP TRIGGER IF EXISTS steps_change_log ON steps;
+DROP TRIGGER IF EXISTS ine_zones_change_log ON ine_zones;
+DROP TRIGGER IF EXISTS parishes_change_log ON parishes;
+DROP TRIGGER IF EXISTS municipalities_change_log ON municipalities;
+DROP TRIGGER IF EXISTS districts_change_log ON districts;
+DROP TRIGGER IF EXISTS countries_change_log ON countries;
+DROP TRIGGER IF EXISTS catalog_values_change_log ON catalog_values;
+DROP FUNCTION IF EXISTS log_change();
+DROP TABLE IF EXISTS change_log CASCADE;
+-- +goose StatementEnd
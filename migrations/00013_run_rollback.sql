@@ -0,0 +1,23 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE import_runs ADD COLUMN rolled_back_by UUID NULL REFERENCES import_runs(id) ON DELETE SET NULL;
+
+CREATE TRIGGER processes_change_log AFTER INSERT OR UPDATE OR DELETE ON processes FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER process_steps_change_log AFTER INSERT OR UPDATE OR DELETE ON process_steps FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER step_header_types_change_log AFTER INSERT OR UPDATE OR DELETE ON step_header_types FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER step_records_change_log AFTER INSERT OR UPDATE OR DELETE ON step_records FOR EACH ROW EXECUTE FUNCTION log_change();
+
+CREATE INDEX idx_change_log_row ON change_log(table_name, row_id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_change_log_row;
+DROP TRIGGER IF EXISTS step_records_change_log ON step_records;
+DROP TRIGGER IF EXISTS step_header_types_change_log ON step_header_types;
+DROP TRIGGER IF EXISTS process_steps_change_log ON process_steps;
+DROP TRIGGER IF EXISTS processes_change_log ON processes;
+ALTER TABLE import_runs DROP COLUMN IF EXISTS rolled_back_by;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+CREATE TABLE active_table_versions (
+	table_code VARCHAR(50) PRIMARY KEY,
+	table_version_id UUID NOT NULL UNIQUE REFERENCES table_versions(id) ON DELETE CASCADE,
+	promoted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TABLE IF EXISTS active_table_versions;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+ALTER TABLE import_runs ADD COLUMN options TEXT NOT NULL DEFAULT '';
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+ALTER TABLE import_runs DROP COLUMN IF EXISTS options;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+-- Header rows used to create their version even when the rows below them
+-- carried another one, leaving versions without rows that shadowed the real
+-- ones. Imports now only create versions that rows are stored under.
+DELETE FROM table_versions tv
+WHERE NOT EXISTS (SELECT 1 FROM catalog_values r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM countries r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM districts r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM municipalities r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM parishes r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM ine_zones r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM steps r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM records r WHERE r.table_version_id = tv.id)
+  AND NOT EXISTS (SELECT 1 FROM fields r WHERE r.table_version_id = tv.id);
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+-- Table versions and catalogs created by an import are logged too, so that
+-- rolling the import back removes them along with their rows.
+CREATE TRIGGER table_versions_change_log AFTER INSERT OR UPDATE OR DELETE ON table_versions FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER catalogs_change_log AFTER INSERT OR UPDATE OR DELETE ON catalogs FOR EACH ROW EXECUTE FUNCTION log_change();
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS catalogs_change_log ON catalogs;
+DROP TRIGGER IF EXISTS table_versions_change_log ON table_versions;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +gooseUp
+-- +goose StatementBegin
+
+-- version_key turns a version into its numbers, so that versions sort
+-- numerically: V01.999 comes before V01.2710, which text order gets wrong.
+CREATE OR REPLACE FUNCTION version_key(version TEXT) RETURNS NUMERIC[] AS $$
+	SELECT COALESCE(array_agg(m[1]::numeric ORDER BY n), '{}')
+	FROM regexp_matches(version, '\d+', 'g') WITH ORDINALITY AS r(m, n)
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE;
+
+-- +goose StatementEnd
+
+-- +gooseDown
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS version_key(TEXT);
+-- +goose StatementEnd